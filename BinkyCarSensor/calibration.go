@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Request the sensor loop to calibrate the car sensors in the given mask,
+// merging with requests it has not yet picked up.
+func (r *i2cRegisters) startCalibration(mask uint16) {
+	if r.carSensorBitsCount < 16 {
+		mask &= (1 << r.carSensorBitsCount) - 1
+	}
+	if mask == 0 {
+		return
+	}
+	println("Start calibration: ", mask)
+	r.calibrating |= mask
+	r.calibrationRequested |= mask
+	select {
+	case pending := <-r.calibrationRequests:
+		mask |= pending
+	default:
+	}
+	r.calibrationRequests <- mask
+}
+
+// Store the calibration of a sensor in the configuration.
+// Calibrations requested through RegCalibrate are saved in flash at once
+// when all of them have finished; calibrations at boot are not saved.
+func (r *i2cRegisters) calibrationDone(result calibrationResult) {
+	println("Calibrated sensor: ", result.Sensor, result.Calibration.Baseline, result.Calibration.Noise)
+	bit := uint16(1) << result.Sensor
+	r.calibrating &^= bit
+	update := r.config.Detection
+	update.Calibration[result.Sensor] = result.Calibration
+	r.setDetection(update)
+	if r.calibrationRequested&bit != 0 {
+		r.calibrationRequested &^= bit
+		r.calibrationFinished |= bit
+	}
+	if r.calibrationRequested == 0 && r.calibrationFinished != 0 {
+		r.saveCalibration(r.calibrationFinished)
+		r.calibrationFinished = 0
+	}
+}
+
+// Save the calibration of the car sensors in the given mask in flash,
+// on top of the last saved configuration, so unsaved changes are not stored.
+func (r *i2cRegisters) saveCalibration(mask uint16) {
+	saved := r.savedConfig
+	for idx := range saved.Detection.Calibration {
+		if mask&(1<<idx) != 0 {
+			saved.Detection.Calibration[idx] = r.config.Detection.Calibration[idx]
+		}
+	}
+	if err := r.configStore.Save(saved); err != nil {
+		println("Failed to save calibration: ", err.Error())
+		return
+	}
+	println("Saved calibration: ", mask)
+	r.savedConfig = saved
+	r.configVersion = config.CurrentVersion
+	r.configFlags = protocol.ConfigFlagLoaded
+	if r.config != saved {
+		r.configFlags |= protocol.ConfigFlagModified
+	}
+}
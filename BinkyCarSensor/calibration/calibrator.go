@@ -0,0 +1,62 @@
+// Package calibration implements the measurement of the idle level
+// (baseline & noise) of a car sensor.
+package calibration
+
+import (
+	"math"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+)
+
+const (
+	// Default number of samples used to calibrate a sensor
+	DefaultSampleCount = 40
+)
+
+// Calibrator collects raw samples of an idle sensor.
+type Calibrator struct {
+	count int
+	total int
+	mean  float64
+	m2    float64 // Sum of squared differences from the mean
+}
+
+// New creates a calibrator that collects the given number of samples.
+func New(sampleCount int) *Calibrator {
+	return &Calibrator{total: sampleCount}
+}
+
+// Add a raw sample.
+func (c *Calibrator) Add(raw uint16) {
+	if c.Done() {
+		return
+	}
+	// Welford's online algorithm
+	c.count++
+	value := float64(raw)
+	delta := value - c.mean
+	c.mean += delta / float64(c.count)
+	c.m2 += delta * (value - c.mean)
+}
+
+// Done returns true when all samples have been collected.
+func (c *Calibrator) Done() bool {
+	return c.count >= c.total
+}
+
+// Result returns the calibration based on the samples collected so far.
+// The noise is at least 1.
+func (c *Calibrator) Result() config.SensorCalibration {
+	if c.count == 0 {
+		return config.SensorCalibration{}
+	}
+	noise := 1.0
+	if c.count > 1 {
+		noise = math.Max(noise, math.Ceil(math.Sqrt(c.m2/float64(c.count-1))))
+	}
+	return config.SensorCalibration{
+		Valid:    true,
+		Baseline: uint16(math.Round(c.mean)),
+		Noise:    uint16(math.Min(noise, math.MaxUint16)),
+	}
+}
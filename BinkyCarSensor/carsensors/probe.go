@@ -0,0 +1,97 @@
+package carsensors
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/devices/ads1115"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus"
+)
+
+// Addresses of the ADS1115 devices in the order of their sensors.
+var Addresses = [...]uint8{ads1115.I2CAddressGround, ads1115.I2CAddressVDD, ads1115.I2CAddressSDA, ads1115.I2CAddressSCL}
+
+// Devices holds the ADS1115 devices found at each of the Addresses.
+type Devices [len(Addresses)]*ads1115.Device
+
+// Probe tries to detect ADS1115 devices at the addresses that have
+// no device yet.
+// Returns the number of newly found devices.
+func (d *Devices) Probe(bus i2cbus.Bus) int {
+	println("Probing ADS1115 devices")
+	found := 0
+	for idx, i2cAddress := range Addresses {
+		if d[idx] != nil {
+			// Already found
+			continue
+		}
+		// Create address and try to read a value
+		if dev, err := ProbeDevice(bus, i2cAddress); err == nil {
+			// Found valid ads1115
+			println("Found ADS1115 at address: ", i2cAddress)
+			d[idx] = dev
+			found++
+		}
+	}
+	return found
+}
+
+// All returns the devices found so far (0..4), ordered by address.
+func (d *Devices) All() []*ads1115.Device {
+	var adsDevs []*ads1115.Device
+	for _, dev := range d {
+		if dev != nil {
+			adsDevs = append(adsDevs, dev)
+		}
+	}
+	return adsDevs
+}
+
+// Complete returns true when devices are found at all addresses.
+func (d *Devices) Complete() bool {
+	for _, dev := range d {
+		if dev == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ProbeDevice probes for the existence of an ADS1115 at the given address.
+// If found, the device is initialized
+func ProbeDevice(bus i2cbus.Bus, i2cAddress uint8) (*ads1115.Device, error) {
+	dev := ads1115.New(bus, i2cAddress)
+	if err := ResetDevice(dev); err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+// ResetDevice resets the given device to desired values.
+func ResetDevice(dev *ads1115.Device) error {
+	if err := dev.Reset(); err != nil {
+		return fmt.Errorf("Reset failed: %w", err)
+	}
+	if err := dev.SetVoltageRangeMilliV(ads1115.ADS1115_RANGE_6144); err != nil {
+		return fmt.Errorf("SetVoltageRangeMilliV failed: %w", err)
+	}
+	if err := dev.SetSingleChannel(0); err != nil {
+		return fmt.Errorf("SetSingleChannel failed: %w", err)
+	}
+	return nil
+}
+
+// ResetDevices resets all given devices, e.g. after a failed probe.
+// Returns the errors of all devices that failed to reset.
+func ResetDevices(adsDevs []*ads1115.Device) error {
+	var allErrs error
+	for idx, dev := range adsDevs {
+		if err := ResetDevice(dev); err != nil {
+			println("Failed to reset ADS1115 device: ", idx, err)
+			allErrs = errors.Join(allErrs, err)
+		} else {
+			println("Succesfully reset ADS1115 device: ", idx)
+		}
+	}
+	return allErrs
+}
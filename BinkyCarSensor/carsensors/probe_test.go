@@ -0,0 +1,128 @@
+package carsensors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/devices/ads1115"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/devices/ads1115/ads1115sim"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus/mockbus"
+)
+
+const (
+	// Config register bits set by ResetDevice (MUX & PGA)
+	resetConfigMask  uint16 = 0x7e00
+	resetConfigValue uint16 = 0x4000 // Channel 0 vs GND, +/- 6144 mV
+)
+
+func TestDevicesProbe(t *testing.T) {
+	bus := mockbus.New()
+	sim0 := ads1115sim.New()
+	sim2 := ads1115sim.New()
+	bus.Attach(ads1115.I2CAddressGround, sim0)
+	bus.Attach(ads1115.I2CAddressSDA, sim2)
+
+	var devs Devices
+	if found := devs.Probe(bus); found != 2 {
+		t.Fatalf("expected 2 devices, got %d", found)
+	}
+	if devs[0] == nil || devs[1] != nil || devs[2] == nil || devs[3] != nil {
+		t.Errorf("expected devices at ground & SDA address, got %v", devs)
+	}
+	if len(devs.All()) != 2 || devs.Complete() {
+		t.Errorf("expected 2 of 4 devices, got %d (complete=%v)", len(devs.All()), devs.Complete())
+	}
+	for _, sim := range []*ads1115sim.Simulator{sim0, sim2} {
+		if cfg := sim.Config(); cfg&resetConfigMask != resetConfigValue {
+			t.Errorf("expected device to be reset, got config 0x%04x", cfg)
+		}
+	}
+}
+
+func TestDevicesProbeMissing(t *testing.T) {
+	bus := mockbus.New()
+	bus.Attach(ads1115.I2CAddressSDA, ads1115sim.New())
+	var devs Devices
+	if found := devs.Probe(bus); found != 1 {
+		t.Fatalf("expected 1 device, got %d", found)
+	}
+	first := devs[2]
+
+	// Devices connected later are found by the next probe,
+	// found devices are not probed again.
+	bus.Attach(ads1115.I2CAddressGround, ads1115sim.New())
+	bus.Attach(ads1115.I2CAddressVDD, ads1115sim.New())
+	bus.Attach(ads1115.I2CAddressSCL, ads1115sim.New())
+	bus.ResetTransactions()
+	if found := devs.Probe(bus); found != 3 {
+		t.Fatalf("expected 3 new devices, got %d", found)
+	}
+	for _, tx := range bus.Transactions() {
+		if tx.Address == ads1115.I2CAddressSDA {
+			t.Errorf("expected found device not to be probed again")
+		}
+	}
+	if devs[2] != first || !devs.Complete() || len(devs.All()) != 4 {
+		t.Errorf("expected all 4 devices, keeping the first, got %v", devs)
+	}
+	if found := devs.Probe(bus); found != 0 {
+		t.Errorf("expected no new devices, got %d", found)
+	}
+}
+
+func TestDevicesProbeNone(t *testing.T) {
+	var devs Devices
+	if found := devs.Probe(mockbus.New()); found != 0 {
+		t.Errorf("expected no devices, got %d", found)
+	}
+	if len(devs.All()) != 0 {
+		t.Errorf("expected no devices, got %d", len(devs.All()))
+	}
+}
+
+func TestProbeDeviceOffline(t *testing.T) {
+	bus := mockbus.New()
+	sim := ads1115sim.New()
+	sim.SetOffline(true)
+	bus.Attach(ads1115.I2CAddressVDD, sim)
+
+	if _, err := ProbeDevice(bus, ads1115.I2CAddressVDD); !errors.Is(err, mockbus.ErrNACK) {
+		t.Errorf("expected ErrNACK, got %v", err)
+	}
+	var devs Devices
+	if found := devs.Probe(bus); found != 0 {
+		t.Errorf("expected offline device to be skipped, got %d devices", found)
+	}
+
+	sim.SetOffline(false)
+	if _, err := ProbeDevice(bus, ads1115.I2CAddressVDD); err != nil {
+		t.Errorf("expected device to be found once online, got %v", err)
+	}
+}
+
+func TestResetDevices(t *testing.T) {
+	bus := mockbus.New()
+	sim0 := ads1115sim.New()
+	sim1 := ads1115sim.New()
+	bus.Attach(ads1115.I2CAddressGround, sim0)
+	bus.Attach(ads1115.I2CAddressVDD, sim1)
+	var devs Devices
+	if found := devs.Probe(bus); found != 2 {
+		t.Fatalf("expected 2 devices, got %d", found)
+	}
+
+	// Simulate a power cycle of the first device & an outage of the second
+	sim0.Reset()
+	sim1.SetOffline(true)
+	if err := ResetDevices(devs.All()); !errors.Is(err, mockbus.ErrNACK) {
+		t.Errorf("expected ErrNACK, got %v", err)
+	}
+	if cfg := sim0.Config(); cfg&resetConfigMask != resetConfigValue {
+		t.Errorf("expected first device to be reset, got config 0x%04x", cfg)
+	}
+
+	sim1.SetOffline(false)
+	if err := ResetDevices(devs.All()); err != nil {
+		t.Errorf("expected reset to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Package carsensors implements the probing of the hall-sensors connected
+// to ADS1115 devices on an I2C bus.
+// It only depends on the i2cbus interface, so it can be tested on a host.
+package carsensors
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/calibration"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/detectors"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/devices/ads1115"
+)
+
+// Sensor represents the state of a single hall sensor
+type Sensor struct {
+	ads        *ads1115.Device
+	adsChannel uint8
+	params     config.DetectionParams
+	detector   config.DetectorParams
+	presence   bool // Stay active while a car is present
+	calib      config.SensorCalibration
+	calibrator *calibration.Calibrator // Set while calibrating
+
+	algorithm detectors.Detector
+	active    bool
+	negative  bool // Polarity of the last detection
+}
+
+const (
+	probeAttemptInterval = time.Millisecond * 10
+	maxProbeDuration     = time.Millisecond * 500
+)
+
+// New initializes a new sensor
+func New(ads *ads1115.Device, adsChannel uint8, params config.DetectionParams, detector config.DetectorParams, presence bool) *Sensor {
+	s := &Sensor{
+		ads:        ads,
+		adsChannel: adsChannel,
+	}
+	s.Configure(params, detector, presence)
+	return s
+}
+
+// Configure the detection parameters, detector & presence mode of the sensor.
+// If the parameters changed, detection restarts.
+func (s *Sensor) Configure(params config.DetectionParams, detector config.DetectorParams, presence bool) {
+	if s.algorithm != nil && params == s.params && detector == s.detector && presence == s.presence {
+		// No changes
+		return
+	}
+	s.params = params
+	s.detector = detector
+	s.presence = presence
+	s.restart()
+}
+
+// SetCalibration sets the idle level of the sensor.
+// If the calibration changed, detection restarts.
+func (s *Sensor) SetCalibration(c config.SensorCalibration) {
+	if c == s.calib {
+		// No changes
+		return
+	}
+	s.calib = c
+	s.restart()
+}
+
+// StartCalibration starts measuring the idle level of the sensor.
+func (s *Sensor) StartCalibration() {
+	s.calibrator = calibration.New(calibration.DefaultSampleCount)
+}
+
+// IsCalibrating returns true while the idle level of the sensor is measured.
+func (s *Sensor) IsCalibrating() bool {
+	return s.calibrator != nil
+}
+
+// CalibrationResult returns the measured idle level and true once
+// the calibration has finished.
+func (s *Sensor) CalibrationResult() (config.SensorCalibration, bool) {
+	if s.calibrator == nil || !s.calibrator.Done() {
+		return config.SensorCalibration{}, false
+	}
+	result := s.calibrator.Result()
+	s.calibrator = nil
+	return result, true
+}
+
+// Restart detection
+func (s *Sensor) restart() {
+	s.active = false
+	s.algorithm = detectors.New(s.detector, s.params, s.calib)
+	if s.presence {
+		s.algorithm = detectors.WithPresence(s.algorithm, s.calib)
+	}
+}
+
+// Probe the current status of the sensor.
+// now is the current time in milliseconds, passed to the detector.
+func (s *Sensor) Probe(now uint32) error {
+	// Select channel
+	if err := s.ads.SetSingleChannel(s.adsChannel); err != nil {
+		return fmt.Errorf("SetSingleChannel failed: %w", err)
+	}
+	// Start measurement
+	if err := s.ads.StartSingleMeasurement(); err != nil {
+		return fmt.Errorf("StartSingleMeasurement failed: %w", err)
+	}
+	// Wait until ready
+	start := time.Now()
+	for {
+		if busy, err := s.ads.IsBusy(); err != nil {
+			return fmt.Errorf("IsBusy failed: %w", err)
+		} else if !busy {
+			// Conversion is ready
+			break
+		}
+		// Check elapsed time
+		if time.Since(start) >= maxProbeDuration {
+			return fmt.Errorf("Probe timeout")
+		}
+		time.Sleep(probeAttemptInterval)
+	}
+	// Read conversion
+	raw, err := s.ads.GetRawConversion()
+	if err != nil {
+		return fmt.Errorf("GetRawConversion failed: %w", err)
+	}
+	if s.calibrator != nil {
+		s.calibrator.Add(raw)
+	}
+	// Pass to detector
+	wasActive := s.active
+	signal := s.algorithm.Next(raw, now)
+	s.active = signal != detectors.SignalNone
+	if s.active && !wasActive {
+		s.negative = signal == detectors.SignalNegative
+	}
+
+	// Update active flag
+	if s.active != wasActive {
+		println(s.adsChannel, raw, s.active)
+	}
+
+	return nil
+}
+
+// IsActive returns true if an active signal is detected.
+func (s *Sensor) IsActive() bool {
+	return s.active
+}
+
+// IsNegative returns true if the sensor output was below its baseline
+// at the start of the current (or last) detection.
+func (s *Sensor) IsNegative() bool {
+	return s.negative
+}
@@ -0,0 +1,112 @@
+package carsensors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/devices/ads1115"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/devices/ads1115/ads1115sim"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus/mockbus"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Create a sensor on channel 1 of a simulated device
+func newTestSensor(t *testing.T) (*Sensor, *ads1115sim.Simulator) {
+	bus := mockbus.New()
+	sim := ads1115sim.New()
+	bus.Attach(ads1115.I2CAddressGround, sim)
+	dev, err := ProbeDevice(bus, ads1115.I2CAddressGround)
+	if err != nil {
+		t.Fatalf("ProbeDevice failed: %s", err)
+	}
+	detector := config.DetectorParams{
+		Detector:     protocol.DetectorHysteresis,
+		OnThreshold:  1000,
+		OffThreshold: 500,
+	}
+	s := New(dev, 1, config.DefaultDetectionParams(), detector, false)
+	// 2.5V at +/- 6144 mV
+	s.SetCalibration(config.SensorCalibration{Valid: true, Baseline: 13333})
+	return s, sim
+}
+
+func TestProbe(t *testing.T) {
+	s, sim := newTestSensor(t)
+	sim.SetVoltage(1, 2.5)
+	if err := s.Probe(0); err != nil {
+		t.Fatalf("Probe failed: %s", err)
+	}
+	if s.IsActive() {
+		t.Error("expected sensor to be inactive at baseline")
+	}
+	if sim.Conversions() != 1 {
+		t.Errorf("expected 1 conversion, got %d", sim.Conversions())
+	}
+
+	// Car passing with the south pole facing the sensor
+	sim.SetVoltage(1, 2.0)
+	if err := s.Probe(50); err != nil {
+		t.Fatalf("Probe failed: %s", err)
+	}
+	if !s.IsActive() || !s.IsNegative() {
+		t.Errorf("expected negative detection, got active=%v negative=%v", s.IsActive(), s.IsNegative())
+	}
+
+	// Signal on another channel is ignored
+	sim.SetVoltage(1, 2.5)
+	sim.SetVoltage(0, 3.5)
+	if err := s.Probe(100); err != nil {
+		t.Fatalf("Probe failed: %s", err)
+	}
+	if s.IsActive() {
+		t.Error("expected sensor to be inactive after car passed")
+	}
+}
+
+func TestProbeOffline(t *testing.T) {
+	s, sim := newTestSensor(t)
+	sim.SetOffline(true)
+	if err := s.Probe(0); !errors.Is(err, mockbus.ErrNACK) {
+		t.Errorf("expected ErrNACK, got %v", err)
+	}
+
+	sim.SetOffline(false)
+	if err := s.Probe(50); err != nil {
+		t.Errorf("expected probe to succeed once online, got %v", err)
+	}
+}
+
+func TestProbeStalledConversion(t *testing.T) {
+	s, sim := newTestSensor(t)
+	sim.SetStallConversions(true)
+	if err := s.Probe(0); err == nil || err.Error() != "Probe timeout" {
+		t.Errorf("expected probe timeout, got %v", err)
+	}
+	if sim.Conversions() != 0 {
+		t.Errorf("expected no conversions, got %d", sim.Conversions())
+	}
+
+	sim.SetStallConversions(false)
+	if err := s.Probe(50); err != nil {
+		t.Errorf("expected probe to succeed after stall, got %v", err)
+	}
+}
+
+func TestCalibration(t *testing.T) {
+	s, sim := newTestSensor(t)
+	sim.SetVoltage(1, 1.5)
+	s.StartCalibration()
+	for now := uint32(0); s.IsCalibrating(); now += 50 {
+		if result, done := s.CalibrationResult(); done {
+			if !result.Valid || result.Baseline != 8000 {
+				t.Errorf("unexpected calibration %+v", result)
+			}
+			return
+		}
+		if err := s.Probe(now); err != nil {
+			t.Fatalf("Probe failed: %s", err)
+		}
+	}
+	t.Error("expected calibration result")
+}
@@ -0,0 +1,1187 @@
+// Package client implements a host-side client for the I2C register protocol
+// of a BinkyCarSensor board.
+// It is intended to be used by a Binky local-worker (regular Linux), not by TinyGo.
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/effects"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Client implements access to a single BinkyCarSensor board.
+type Client struct {
+	bus     i2cbus.Bus
+	address uint8
+}
+
+// Version of the firmware running on a board.
+type Version struct {
+	Major uint8
+	Minor uint8
+	Patch uint8
+}
+
+// String returns the version as major.minor.patch
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// New initializes a new client for the board at the given address on the given bus.
+func New(bus i2cbus.Bus, address uint8) *Client {
+	return &Client{
+		bus:     bus,
+		address: address,
+	}
+}
+
+// Address returns the I2C address of the board.
+func (c *Client) Address() uint8 {
+	return c.address
+}
+
+// Version returns the firmware version of the board.
+func (c *Client) Version() (Version, error) {
+	var result Version
+	var err error
+	if result.Major, err = c.readByte(protocol.RegVersionMajor); err != nil {
+		return Version{}, fmt.Errorf("Failed to read major version: %w", err)
+	}
+	if result.Minor, err = c.readByte(protocol.RegVersionMinor); err != nil {
+		return Version{}, fmt.Errorf("Failed to read minor version: %w", err)
+	}
+	if result.Patch, err = c.readByte(protocol.RegVersionPatch); err != nil {
+		return Version{}, fmt.Errorf("Failed to read patch version: %w", err)
+	}
+	return result, nil
+}
+
+// SensorCount returns the number of car sensor bits detected by the board.
+func (c *Client) SensorCount() (uint8, error) {
+	result, err := c.readByte(protocol.RegCarSensorCount)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read car sensor count: %w", err)
+	}
+	return result, nil
+}
+
+// OutputCount returns the number of binary output pins found on
+// PCF8574 devices attached to the board.
+func (c *Client) OutputCount() (uint8, error) {
+	result, err := c.readByte(protocol.RegI2COutputCount)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read I2C output count: %w", err)
+	}
+	return result, nil
+}
+
+// SensorState returns a bitmap of all car sensors that have been active
+// since the previous call.
+// Bit N is set when sensor N has been active.
+func (c *Client) SensorState() (uint16, error) {
+	result, err := c.readUint16(protocol.RegCarSensorState)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read car sensor state: %w", err)
+	}
+	return result, nil
+}
+
+// RisingEdges returns a bitmap of all car sensors that became active
+// since their edges were last acknowledged.
+func (c *Client) RisingEdges() (uint16, error) {
+	result, err := c.readUint16(protocol.RegCarSensorRisingEdges)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read car sensor rising edges: %w", err)
+	}
+	return result, nil
+}
+
+// FallingEdges returns a bitmap of all car sensors that became inactive
+// since their edges were last acknowledged.
+func (c *Client) FallingEdges() (uint16, error) {
+	result, err := c.readUint16(protocol.RegCarSensorFallingEdges)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read car sensor falling edges: %w", err)
+	}
+	return result, nil
+}
+
+// Polarity returns all car sensors whose current (or last) detection has
+// negative polarity, i.e. the sensor output was below its baseline.
+// The polarity depends on the magnet pole facing the sensor.
+func (c *Client) Polarity() (uint16, error) {
+	result, err := c.readUint16(protocol.RegCarSensorPolarity)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read car sensor polarity: %w", err)
+	}
+	return result, nil
+}
+
+// AckEdges clears the rising & falling edges of all car sensors in the given mask.
+func (c *Client) AckEdges(mask uint16) error {
+	if err := c.writeUint16(protocol.RegCarSensorAckEdges, mask); err != nil {
+		return fmt.Errorf("Failed to acknowledge car sensor edges: %w", err)
+	}
+	return nil
+}
+
+// PassCount returns the number of times the car sensor with given index (0..15)
+// became active. The counter wraps around at 0xffff.
+func (c *Client) PassCount(sensor uint8) (uint16, error) {
+	if sensor >= protocol.MaxSensorCount {
+		return 0, fmt.Errorf("Invalid car sensor index: %d", sensor)
+	}
+	result, err := c.readUint16(protocol.RegCarSensorPassCount0 + sensor)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read car sensor pass count: %w", err)
+	}
+	return result, nil
+}
+
+// ResetPassCounts resets the pass counters of all car sensors in the given mask.
+func (c *Client) ResetPassCounts(mask uint16) error {
+	if err := c.writeUint16(protocol.RegCarSensorResetPassCounts, mask); err != nil {
+		return fmt.Errorf("Failed to reset car sensor pass counts: %w", err)
+	}
+	return nil
+}
+
+// Event is a single car sensor transition.
+type Event struct {
+	// Index of the sensor
+	Sensor uint8
+	// Set if the sensor became active, unset if it became inactive.
+	Active bool
+	// Set if the detection has negative polarity (sensor output below its baseline).
+	Negative bool
+	// Time of the transition since boot of the board.
+	Timestamp time.Duration
+	// Set if events have been dropped by the board before this event was read.
+	Overflow bool
+}
+
+// EventStatus returns the number of queued car sensor events and
+// true if events have been dropped since the queue was last drained.
+func (c *Client) EventStatus() (int, bool, error) {
+	w := [1]uint8{protocol.RegCarSensorEventStatus}
+	var r [2]uint8
+	if err := c.bus.Tx(uint16(c.address), w[:], r[:]); err != nil {
+		return 0, false, fmt.Errorf("Failed to read car sensor event status: %w", err)
+	}
+	return int(r[0]), r[1]&protocol.EventFlagOverflow != 0, nil
+}
+
+// NextEvent removes the oldest car sensor event from the queue of the board
+// and returns it.
+// Returns false if the queue was empty.
+func (c *Client) NextEvent() (Event, bool, error) {
+	w := [1]uint8{protocol.RegCarSensorEvent}
+	var r [protocol.EventRecordSize]uint8
+	if err := c.bus.Tx(uint16(c.address), w[:], r[:]); err != nil {
+		return Event{}, false, fmt.Errorf("Failed to read car sensor event: %w", err)
+	}
+	flags := r[0]
+	if flags&protocol.EventFlagValid == 0 {
+		return Event{}, false, nil
+	}
+	ms := uint32(r[2]) | (uint32(r[3]) << 8) | (uint32(r[4]) << 16) | (uint32(r[5]) << 24)
+	return Event{
+		Sensor:    r[1],
+		Active:    flags&protocol.EventFlagActive != 0,
+		Negative:  flags&protocol.EventFlagNegative != 0,
+		Timestamp: time.Duration(ms) * time.Millisecond,
+		Overflow:  flags&protocol.EventFlagOverflow != 0,
+	}, true, nil
+}
+
+// Events drains the queue of car sensor events of the board
+// and returns all events in order.
+func (c *Client) Events() ([]Event, error) {
+	var result []Event
+	for {
+		e, ok, err := c.NextEvent()
+		if err != nil {
+			return result, err
+		}
+		if !ok {
+			return result, nil
+		}
+		result = append(result, e)
+	}
+}
+
+// DetectionParams returns the detection parameters of the car sensor with
+// given index (or protocol.DetectionGlobal) and true if the sensor overrides
+// the global parameters.
+func (c *Client) DetectionParams(sensor uint8) (config.DetectionParams, bool, error) {
+	if err := c.bus.Tx(uint16(c.address), []byte{protocol.RegDetectionParams, sensor}, nil); err != nil {
+		return config.DetectionParams{}, false, fmt.Errorf("Failed to select detection parameters: %w", err)
+	}
+	w := [1]uint8{protocol.RegDetectionParams}
+	var r [protocol.DetectionRecordSize]uint8
+	if err := c.bus.Tx(uint16(c.address), w[:], r[:]); err != nil {
+		return config.DetectionParams{}, false, fmt.Errorf("Failed to read detection parameters: %w", err)
+	}
+	return config.DecodeDetectionRecord(r[:])
+}
+
+// SetDetectionParams sets the detection parameters of the car sensor with
+// given index (or protocol.DetectionGlobal).
+// If override is false for a sensor, it uses the global parameters again.
+func (c *Client) SetDetectionParams(sensor uint8, params config.DetectionParams, override bool) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	w := append([]byte{protocol.RegDetectionParams, sensor}, params.EncodeRecord(override)...)
+	if err := c.bus.Tx(uint16(c.address), w, nil); err != nil {
+		return fmt.Errorf("Failed to write detection parameters: %w", err)
+	}
+	return nil
+}
+
+// ProbeInterval returns the interval between probes of all car sensors.
+func (c *Client) ProbeInterval() (time.Duration, error) {
+	result, err := c.readUint16(protocol.RegProbeInterval)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read probe interval: %w", err)
+	}
+	return time.Duration(result) * time.Millisecond, nil
+}
+
+// SetProbeInterval sets the interval between probes of all car sensors.
+func (c *Client) SetProbeInterval(interval time.Duration) error {
+	if err := c.writeUint16(protocol.RegProbeInterval, uint16(interval/time.Millisecond)); err != nil {
+		return fmt.Errorf("Failed to write probe interval: %w", err)
+	}
+	return nil
+}
+
+// Detector returns the detector settings of the car sensor with given index.
+func (c *Client) Detector(sensor uint8) (config.DetectorParams, error) {
+	if sensor >= protocol.MaxSensorCount {
+		return config.DetectorParams{}, fmt.Errorf("Invalid sensor index: %d", sensor)
+	}
+	if err := c.bus.Tx(uint16(c.address), []byte{protocol.RegDetector, sensor}, nil); err != nil {
+		return config.DetectorParams{}, fmt.Errorf("Failed to select detector: %w", err)
+	}
+	var r [protocol.DetectorRecordSize]uint8
+	if err := c.readBytes(protocol.RegDetector, r[:]); err != nil {
+		return config.DetectorParams{}, fmt.Errorf("Failed to read detector: %w", err)
+	}
+	return config.DecodeDetectorRecord(r[:])
+}
+
+// SetDetector selects & configures the detector of the car sensor with given index.
+// protocol.DetectorHysteresis requires a calibrated sensor.
+func (c *Client) SetDetector(sensor uint8, params config.DetectorParams) error {
+	if sensor >= protocol.MaxSensorCount {
+		return fmt.Errorf("Invalid sensor index: %d", sensor)
+	}
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	w := append([]byte{protocol.RegDetector, sensor}, params.EncodeRecord()...)
+	if err := c.bus.Tx(uint16(c.address), w, nil); err != nil {
+		return fmt.Errorf("Failed to write detector: %w", err)
+	}
+	return nil
+}
+
+// PresenceMode returns a mask of the car sensors in presence mode.
+func (c *Client) PresenceMode() (uint16, error) {
+	result, err := c.readUint16(protocol.RegPresenceMode)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read presence mode: %w", err)
+	}
+	return result, nil
+}
+
+// SetPresenceMode sets the car sensors in presence mode.
+// These sensors stay active for as long as a car is present, e.g. a car stopped
+// at a bus stop. Presence mode requires calibrated sensors.
+func (c *Client) SetPresenceMode(mask uint16) error {
+	if err := c.writeUint16(protocol.RegPresenceMode, mask); err != nil {
+		return fmt.Errorf("Failed to write presence mode: %w", err)
+	}
+	return nil
+}
+
+// Calibration returns the calibrated idle level of the car sensor with given index.
+func (c *Client) Calibration(sensor uint8) (config.SensorCalibration, error) {
+	if sensor >= protocol.MaxSensorCount {
+		return config.SensorCalibration{}, fmt.Errorf("Invalid sensor index: %d", sensor)
+	}
+	if err := c.bus.Tx(uint16(c.address), []byte{protocol.RegCalibration, sensor}, nil); err != nil {
+		return config.SensorCalibration{}, fmt.Errorf("Failed to select calibration: %w", err)
+	}
+	var r [protocol.CalibrationRecordSize]uint8
+	if err := c.readBytes(protocol.RegCalibration, r[:]); err != nil {
+		return config.SensorCalibration{}, fmt.Errorf("Failed to read calibration: %w", err)
+	}
+	return config.DecodeCalibrationRecord(r[:])
+}
+
+// SetCalibration sets the idle level of the car sensor with given index.
+// Use a calibration that is not valid to detect without baseline.
+func (c *Client) SetCalibration(sensor uint8, calib config.SensorCalibration) error {
+	if sensor >= protocol.MaxSensorCount {
+		return fmt.Errorf("Invalid sensor index: %d", sensor)
+	}
+	w := append([]byte{protocol.RegCalibration, sensor}, calib.EncodeRecord()...)
+	if err := c.bus.Tx(uint16(c.address), w, nil); err != nil {
+		return fmt.Errorf("Failed to write calibration: %w", err)
+	}
+	return nil
+}
+
+// Calibrate starts the calibration of the car sensors in the given mask.
+// No cars must be near these sensors until Calibrating no longer reports them.
+// The results are stored in flash.
+func (c *Client) Calibrate(mask uint16) error {
+	if err := c.writeUint16(protocol.RegCalibrate, mask); err != nil {
+		return fmt.Errorf("Failed to start calibration: %w", err)
+	}
+	return nil
+}
+
+// Calibrating returns a mask of the car sensors being calibrated.
+func (c *Client) Calibrating() (uint16, error) {
+	result, err := c.readUint16(protocol.RegCalibrate)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read calibrating sensors: %w", err)
+	}
+	return result, nil
+}
+
+// CalibrationMode returns the calibration flags (protocol.CalibrationModeXyz).
+func (c *Client) CalibrationMode() (uint8, error) {
+	result, err := c.readByte(protocol.RegCalibrationMode)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read calibration mode: %w", err)
+	}
+	return result, nil
+}
+
+// SetCalibrationMode sets the calibration flags (protocol.CalibrationModeXyz).
+func (c *Client) SetCalibrationMode(mode uint8) error {
+	if err := c.writeByte(protocol.RegCalibrationMode, mode); err != nil {
+		return fmt.Errorf("Failed to write calibration mode: %w", err)
+	}
+	return nil
+}
+
+// ConfigStatus returns the version of the configuration stored in the flash
+// of the board (0 if none) and its status flags (protocol.ConfigFlagXyz).
+func (c *Client) ConfigStatus() (uint8, uint8, error) {
+	w := [1]uint8{protocol.RegConfigStatus}
+	var r [2]uint8
+	if err := c.bus.Tx(uint16(c.address), w[:], r[:]); err != nil {
+		return 0, 0, fmt.Errorf("Failed to read configuration status: %w", err)
+	}
+	return r[0], r[1], nil
+}
+
+// ConfiguredI2CAddress returns the configured I2C address of the board.
+// 0 means the address is selected by IO1.
+func (c *Client) ConfiguredI2CAddress() (uint8, error) {
+	result, err := c.readByte(protocol.RegConfigI2CAddress)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read configured I2C address: %w", err)
+	}
+	return result, nil
+}
+
+// SetConfiguredI2CAddress sets the I2C address of the board,
+// used after the next boot (once saved).
+// Use 0 to select the address with IO1.
+func (c *Client) SetConfiguredI2CAddress(address uint8) error {
+	if err := c.writeByte(protocol.RegConfigI2CAddress, address); err != nil {
+		return fmt.Errorf("Failed to write configured I2C address: %w", err)
+	}
+	return nil
+}
+
+// PinModes returns the power-on mode (protocol.PinModeXyz) of all on-pcb pins.
+func (c *Client) PinModes() ([protocol.IOPinCount]uint8, error) {
+	var result [protocol.IOPinCount]uint8
+	if err := c.readBytes(protocol.RegConfigPinModes, result[:]); err != nil {
+		return result, fmt.Errorf("Failed to read pin modes: %w", err)
+	}
+	return result, nil
+}
+
+// SetPinModes sets the power-on mode (protocol.PinModeXyz) of all on-pcb pins.
+func (c *Client) SetPinModes(modes [protocol.IOPinCount]uint8) error {
+	if err := c.writeBytes(protocol.RegConfigPinModes, modes[:]); err != nil {
+		return fmt.Errorf("Failed to write pin modes: %w", err)
+	}
+	return nil
+}
+
+// OutputDefaults returns the power-on value of the on-pcb output pins and
+// of all PCF8574 output devices.
+func (c *Client) OutputDefaults() (uint8, [protocol.MaxPCFDevices]uint8, error) {
+	var r [1 + protocol.MaxPCFDevices]uint8
+	var pcf [protocol.MaxPCFDevices]uint8
+	if err := c.readBytes(protocol.RegConfigOutputDefaults, r[:]); err != nil {
+		return 0, pcf, fmt.Errorf("Failed to read output defaults: %w", err)
+	}
+	copy(pcf[:], r[1:])
+	return r[0], pcf, nil
+}
+
+// SetOutputDefaults sets the power-on value of the on-pcb output pins and
+// of all PCF8574 output devices.
+func (c *Client) SetOutputDefaults(outputs uint8, pcf [protocol.MaxPCFDevices]uint8) error {
+	if err := c.writeBytes(protocol.RegConfigOutputDefaults, append([]byte{outputs}, pcf[:]...)); err != nil {
+		return fmt.Errorf("Failed to write output defaults: %w", err)
+	}
+	return nil
+}
+
+// PWMDefaults returns the power-on PWM value of all on-pcb pins.
+func (c *Client) PWMDefaults() ([protocol.IOPinCount]uint8, error) {
+	var result [protocol.IOPinCount]uint8
+	if err := c.readBytes(protocol.RegConfigPWMDefaults, result[:]); err != nil {
+		return result, fmt.Errorf("Failed to read PWM defaults: %w", err)
+	}
+	return result, nil
+}
+
+// SetPWMDefaults sets the power-on PWM value of all on-pcb pins.
+func (c *Client) SetPWMDefaults(values [protocol.IOPinCount]uint8) error {
+	if err := c.writeBytes(protocol.RegConfigPWMDefaults, values[:]); err != nil {
+		return fmt.Errorf("Failed to write PWM defaults: %w", err)
+	}
+	return nil
+}
+
+// Label returns the label of the board.
+func (c *Client) Label() (string, error) {
+	var r [protocol.LabelMaxSize]uint8
+	if err := c.readBytes(protocol.RegConfigLabel, r[:]); err != nil {
+		return "", fmt.Errorf("Failed to read label: %w", err)
+	}
+	return strings.TrimRight(string(r[:]), "\x00"), nil
+}
+
+// SetLabel sets the label of the board (at most protocol.LabelMaxSize bytes).
+func (c *Client) SetLabel(label string) error {
+	if len(label) > protocol.LabelMaxSize {
+		return fmt.Errorf("Label too long: %d", len(label))
+	}
+	var w [protocol.LabelMaxSize]uint8
+	copy(w[:], label)
+	if err := c.writeBytes(protocol.RegConfigLabel, w[:]); err != nil {
+		return fmt.Errorf("Failed to write label: %w", err)
+	}
+	return nil
+}
+
+// FactoryReset erases the configuration from the flash of the board and
+// restores the default configuration.
+func (c *Client) FactoryReset() error {
+	if err := c.writeByte(protocol.RegFactoryReset, protocol.FactoryResetMagic); err != nil {
+		return fmt.Errorf("Failed to reset configuration: %w", err)
+	}
+	return nil
+}
+
+// SaveConfig stores the current configuration of the board in its flash,
+// so it survives power cycles.
+func (c *Client) SaveConfig() error {
+	if err := c.writeByte(protocol.RegSaveConfig, protocol.ConfigSaveMagic); err != nil {
+		return fmt.Errorf("Failed to save configuration: %w", err)
+	}
+	return nil
+}
+
+// BusStats holds the transaction statistics of a single device on the
+// I2C bus between the board and its ADS1115 & PCF8574 devices.
+type BusStats struct {
+	// I2C address of the device
+	Address uint8
+	// Number of transactions (including failed ones)
+	Transactions uint32
+	// Number of failed transactions
+	Errors uint32
+}
+
+// BusStats returns the transaction statistics of the device with given
+// I2C address on the I2C bus of the board.
+func (c *Client) BusStats(deviceAddress uint8) (BusStats, error) {
+	if err := c.writeByte(protocol.RegBusStats, deviceAddress); err != nil {
+		return BusStats{}, fmt.Errorf("Failed to select bus device: %w", err)
+	}
+	var r [protocol.BusStatsRecordSize]uint8
+	if err := c.readBytes(protocol.RegBusStats, r[:]); err != nil {
+		return BusStats{}, fmt.Errorf("Failed to read bus statistics: %w", err)
+	}
+	return BusStats{
+		Address:      r[0],
+		Transactions: uint32(r[1]) | (uint32(r[2]) << 8) | (uint32(r[3]) << 16) | (uint32(r[4]) << 24),
+		Errors:       uint32(r[5]) | (uint32(r[6]) << 8) | (uint32(r[7]) << 16) | (uint32(r[8]) << 24),
+	}, nil
+}
+
+// ResetBusStats resets the transaction statistics of all devices on the
+// I2C bus of the board.
+func (c *Client) ResetBusStats() error {
+	if err := c.writeByte(protocol.RegBusResetStats, 1); err != nil {
+		return fmt.Errorf("Failed to reset bus statistics: %w", err)
+	}
+	return nil
+}
+
+// FailsafeStatus returns true if the outputs are in their safe state because
+// of communication loss (active) and true if the failsafe has been triggered
+// since the last AckFailsafe.
+func (c *Client) FailsafeStatus() (bool, bool, error) {
+	flags, err := c.readByte(protocol.RegFailsafeStatus)
+	if err != nil {
+		return false, false, fmt.Errorf("Failed to read failsafe status: %w", err)
+	}
+	return flags&protocol.FailsafeFlagActive != 0, flags&protocol.FailsafeFlagTriggered != 0, nil
+}
+
+// AckFailsafe clears the triggered flag of the failsafe.
+func (c *Client) AckFailsafe() error {
+	if err := c.writeByte(protocol.RegFailsafeStatus, 0); err != nil {
+		return fmt.Errorf("Failed to acknowledge failsafe: %w", err)
+	}
+	return nil
+}
+
+// FailsafeTimeout returns the time without valid transactions after which
+// the board drives its outputs to their safe state (0 means disabled).
+func (c *Client) FailsafeTimeout() (time.Duration, error) {
+	value, err := c.readUint16(protocol.RegFailsafeTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read failsafe timeout: %w", err)
+	}
+	return time.Duration(value) * time.Millisecond, nil
+}
+
+// SetFailsafeTimeout sets the time without valid transactions after which
+// the board drives its outputs to their safe state (0 disables the failsafe).
+func (c *Client) SetFailsafeTimeout(timeout time.Duration) error {
+	if err := c.writeUint16(protocol.RegFailsafeTimeout, uint16(timeout/time.Millisecond)); err != nil {
+		return fmt.Errorf("Failed to write failsafe timeout: %w", err)
+	}
+	return nil
+}
+
+// FailsafeOutputs returns the safe value of the on-pcb output pins and
+// of all PCF8574 output devices.
+func (c *Client) FailsafeOutputs() (uint8, [protocol.MaxPCFDevices]uint8, error) {
+	var r [1 + protocol.MaxPCFDevices]uint8
+	var pcf [protocol.MaxPCFDevices]uint8
+	if err := c.readBytes(protocol.RegFailsafeOutputs, r[:]); err != nil {
+		return 0, pcf, fmt.Errorf("Failed to read failsafe outputs: %w", err)
+	}
+	copy(pcf[:], r[1:])
+	return r[0], pcf, nil
+}
+
+// SetFailsafeOutputs sets the safe value of the on-pcb output pins and
+// of all PCF8574 output devices.
+func (c *Client) SetFailsafeOutputs(outputs uint8, pcf [protocol.MaxPCFDevices]uint8) error {
+	if err := c.writeBytes(protocol.RegFailsafeOutputs, append([]byte{outputs}, pcf[:]...)); err != nil {
+		return fmt.Errorf("Failed to write failsafe outputs: %w", err)
+	}
+	return nil
+}
+
+// FailsafePWM returns the safe PWM value (or servo position) of all on-pcb pins.
+func (c *Client) FailsafePWM() ([protocol.IOPinCount]uint8, error) {
+	var result [protocol.IOPinCount]uint8
+	if err := c.readBytes(protocol.RegFailsafePWM, result[:]); err != nil {
+		return result, fmt.Errorf("Failed to read failsafe PWM values: %w", err)
+	}
+	return result, nil
+}
+
+// SetFailsafePWM sets the safe PWM value (or servo position) of all on-pcb pins.
+func (c *Client) SetFailsafePWM(values [protocol.IOPinCount]uint8) error {
+	if err := c.writeBytes(protocol.RegFailsafePWM, values[:]); err != nil {
+		return fmt.Errorf("Failed to write failsafe PWM values: %w", err)
+	}
+	return nil
+}
+
+// ResetStatus is the cause of the last reset of a board.
+type ResetStatus struct {
+	// Cause of the reset (protocol.ResetCauseXyz)
+	Cause uint8
+	// Loops that stalled before a watchdog reset (protocol.LoopXyz mask)
+	StalledLoops uint8
+}
+
+// ResetStatus returns the cause of the last reset of the board.
+func (c *Client) ResetStatus() (ResetStatus, error) {
+	var r [protocol.ResetStatusSize]uint8
+	if err := c.readBytes(protocol.RegResetStatus, r[:]); err != nil {
+		return ResetStatus{}, fmt.Errorf("Failed to read reset status: %w", err)
+	}
+	return ResetStatus{
+		Cause:        r[0],
+		StalledLoops: r[1],
+	}, nil
+}
+
+// PinMode returns the current mode (protocol.PinModeXyz) of the on-pcb pin with given index (0..7).
+func (c *Client) PinMode(pin uint8) (uint8, error) {
+	if pin >= protocol.IOPinCount {
+		return 0, fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	mode, err := c.readByte(protocol.RegPinMode0 + pin)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read pin mode: %w", err)
+	}
+	return mode, nil
+}
+
+// SetPinMode switches the on-pcb pin with given index (0..7) to the given mode (protocol.PinModeXyz).
+// Rejected requests are reported by PinErrors.
+func (c *Client) SetPinMode(pin uint8, mode uint8) error {
+	if pin >= protocol.IOPinCount {
+		return fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	if err := c.writeByte(protocol.RegPinMode0+pin, mode); err != nil {
+		return fmt.Errorf("Failed to write pin mode: %w", err)
+	}
+	return nil
+}
+
+// PinErrors returns the on-pcb pins whose last request was rejected and
+// the reason of the last rejection (protocol.PinErrorXyz).
+func (c *Client) PinErrors() (uint8, uint8, error) {
+	var r [protocol.PinErrorRecordSize]uint8
+	if err := c.readBytes(protocol.RegPinErrors, r[:]); err != nil {
+		return 0, 0, fmt.Errorf("Failed to read pin errors: %w", err)
+	}
+	return r[0], r[1], nil
+}
+
+// AckPinErrors clears the rejected requests of the on-pcb pins in the given mask.
+func (c *Client) AckPinErrors(mask uint8) error {
+	if err := c.writeByte(protocol.RegPinErrors, mask); err != nil {
+		return fmt.Errorf("Failed to acknowledge pin errors: %w", err)
+	}
+	return nil
+}
+
+// InputState returns the on-pcb inputs that have been active since the
+// previous call (bit N is set for pin N).
+func (c *Client) InputState() (uint8, error) {
+	value, err := c.readByte(protocol.RegInputState)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read input state: %w", err)
+	}
+	return value, nil
+}
+
+// InputRisingEdges returns the on-pcb inputs that became active since
+// their edges were last acknowledged.
+func (c *Client) InputRisingEdges() (uint8, error) {
+	value, err := c.readByte(protocol.RegInputRisingEdges)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read input rising edges: %w", err)
+	}
+	return value, nil
+}
+
+// InputFallingEdges returns the on-pcb inputs that became inactive since
+// their edges were last acknowledged.
+func (c *Client) InputFallingEdges() (uint8, error) {
+	value, err := c.readByte(protocol.RegInputFallingEdges)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read input falling edges: %w", err)
+	}
+	return value, nil
+}
+
+// AckInputEdges clears the rising & falling edges of the on-pcb inputs in the given mask.
+func (c *Client) AckInputEdges(mask uint8) error {
+	if err := c.writeByte(protocol.RegInputAckEdges, mask); err != nil {
+		return fmt.Errorf("Failed to acknowledge input edges: %w", err)
+	}
+	return nil
+}
+
+// InputParams returns the input settings of the on-pcb pin with given index (0..7).
+func (c *Client) InputParams(pin uint8) (config.InputParams, error) {
+	if pin >= protocol.IOPinCount {
+		return config.InputParams{}, fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	var r [protocol.InputRecordSize]uint8
+	if err := c.readBytes(protocol.RegInputConfig0+pin, r[:]); err != nil {
+		return config.InputParams{}, fmt.Errorf("Failed to read input settings: %w", err)
+	}
+	return config.DecodeInputRecord(r[:])
+}
+
+// SetInputParams sets the input settings of the on-pcb pin with given index (0..7).
+// An input mode switches the pin to input, protocol.InputModeNone returns it to digital output.
+func (c *Client) SetInputParams(pin uint8, params config.InputParams) error {
+	if pin >= protocol.IOPinCount {
+		return fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	if err := c.writeBytes(protocol.RegInputConfig0+pin, params.EncodeRecord()); err != nil {
+		return fmt.Errorf("Failed to write input settings: %w", err)
+	}
+	return nil
+}
+
+// PairResult is the last speed & direction measurement of a car sensor pair.
+type PairResult struct {
+	// Set if the pair has measured a car
+	Valid bool
+	// Set if the car went from the second to the first sensor
+	Reverse bool
+	// Set if the measurement has not been read before
+	New bool
+	// Number of measurements (wraps)
+	Count uint8
+	// Time between the activations of both sensors
+	TransitTime time.Duration
+	// Speed of the car in millimeters per second
+	Speed uint16
+	// Scale speed of the car in km/h
+	ScaleSpeed uint16
+}
+
+// PairParams returns the settings of the car sensor pair with given index.
+func (c *Client) PairParams(pair uint8) (config.PairParams, error) {
+	if pair >= protocol.MaxSensorPairs {
+		return config.PairParams{}, fmt.Errorf("Invalid pair index: %d", pair)
+	}
+	var r [protocol.PairConfigRecordSize]uint8
+	if err := c.readBytes(protocol.RegPairConfig0+pair, r[:]); err != nil {
+		return config.PairParams{}, fmt.Errorf("Failed to read sensor pair: %w", err)
+	}
+	return config.DecodePairRecord(r[:])
+}
+
+// SetPairParams sets the settings of the car sensor pair with given index.
+// A spacing of 0 disables the pair.
+func (c *Client) SetPairParams(pair uint8, params config.PairParams) error {
+	if pair >= protocol.MaxSensorPairs {
+		return fmt.Errorf("Invalid pair index: %d", pair)
+	}
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	if err := c.writeBytes(protocol.RegPairConfig0+pair, params.EncodeRecord()); err != nil {
+		return fmt.Errorf("Failed to write sensor pair: %w", err)
+	}
+	return nil
+}
+
+// PairResult returns the last measurement of the car sensor pair with given index
+// and marks it as read.
+func (c *Client) PairResult(pair uint8) (PairResult, error) {
+	if pair >= protocol.MaxSensorPairs {
+		return PairResult{}, fmt.Errorf("Invalid pair index: %d", pair)
+	}
+	var r [protocol.PairResultRecordSize]uint8
+	if err := c.readBytes(protocol.RegPairResult0+pair, r[:]); err != nil {
+		return PairResult{}, fmt.Errorf("Failed to read sensor pair result: %w", err)
+	}
+	ms := uint16(r[2]) | (uint16(r[3]) << 8)
+	return PairResult{
+		Valid:       r[0]&protocol.PairFlagValid != 0,
+		Reverse:     r[0]&protocol.PairFlagReverse != 0,
+		New:         r[0]&protocol.PairFlagNew != 0,
+		Count:       r[1],
+		TransitTime: time.Duration(ms) * time.Millisecond,
+		Speed:       uint16(r[4]) | (uint16(r[5]) << 8),
+		ScaleSpeed:  uint16(r[6]) | (uint16(r[7]) << 8),
+	}, nil
+}
+
+// PulseOutputIndex returns the index of an output for use with PulseParams.
+// Use dev=-1 for the on-pcb output pins or dev=N for PCF8574 device N.
+func PulseOutputIndex(dev int, bit uint8) uint8 {
+	return uint8((dev+1)*8) + bit
+}
+
+// PulseParams returns the pulse & safety settings of the output with given
+// index (see PulseOutputIndex).
+func (c *Client) PulseParams(output uint8) (config.PulseParams, error) {
+	if output >= protocol.PulseOutputCount {
+		return config.PulseParams{}, fmt.Errorf("Invalid output index: %d", output)
+	}
+	if err := c.writeByte(protocol.RegPulseConfig, output); err != nil {
+		return config.PulseParams{}, fmt.Errorf("Failed to select pulse output: %w", err)
+	}
+	var r [protocol.PulseRecordSize]uint8
+	if err := c.readBytes(protocol.RegPulseConfig, r[:]); err != nil {
+		return config.PulseParams{}, fmt.Errorf("Failed to read pulse parameters: %w", err)
+	}
+	return config.DecodePulseRecord(r[:])
+}
+
+// SetPulseParams sets the pulse & safety settings of the output with given
+// index (see PulseOutputIndex).
+func (c *Client) SetPulseParams(output uint8, params config.PulseParams) error {
+	if output >= protocol.PulseOutputCount {
+		return fmt.Errorf("Invalid output index: %d", output)
+	}
+	if err := c.writeBytes(protocol.RegPulseConfig, append([]byte{output}, params.EncodeRecord()...)); err != nil {
+		return fmt.Errorf("Failed to write pulse parameters: %w", err)
+	}
+	return nil
+}
+
+// PulseTripped returns the outputs that were switched off because they
+// exceeded their maximum on-time.
+// Element 0 holds the on-pcb output pins, element 1+N PCF8574 device N.
+func (c *Client) PulseTripped() ([1 + protocol.MaxPCFDevices]uint8, error) {
+	var result [1 + protocol.MaxPCFDevices]uint8
+	if err := c.readBytes(protocol.RegPulseTripped, result[:]); err != nil {
+		return result, fmt.Errorf("Failed to read tripped outputs: %w", err)
+	}
+	return result, nil
+}
+
+// AckPulseTripped clears the tripped state of the outputs in the given masks
+// (same layout as PulseTripped).
+func (c *Client) AckPulseTripped(masks [1 + protocol.MaxPCFDevices]uint8) error {
+	if err := c.writeBytes(protocol.RegPulseTripped, masks[:]); err != nil {
+		return fmt.Errorf("Failed to acknowledge tripped outputs: %w", err)
+	}
+	return nil
+}
+
+// SetOutputs sets the 8 on-pcb output pins.
+// Bit N controls pin N.
+func (c *Client) SetOutputs(bits uint8) error {
+	if err := c.writeByte(protocol.RegOutput, bits); err != nil {
+		return fmt.Errorf("Failed to write outputs: %w", err)
+	}
+	return nil
+}
+
+// OutputStatus is the status of a group of 8 outputs
+// (the on-pcb output pins or a PCF8574 device).
+type OutputStatus struct {
+	// Value last applied to the outputs
+	Value uint8
+	// Set if a value has been applied to the outputs
+	Written bool
+	// Set if the last write to the outputs failed
+	Failed bool
+	// Set while writes to the outputs are queued
+	Pending bool
+}
+
+// Outputs returns the status of the on-pcb output pins.
+func (c *Client) Outputs() (OutputStatus, error) {
+	return c.outputStatus(protocol.RegOutput)
+}
+
+// PCFOutputs returns the status of the PCF8574 output device with given index.
+func (c *Client) PCFOutputs(dev uint8) (OutputStatus, error) {
+	if dev >= protocol.MaxPCFDevices {
+		return OutputStatus{}, fmt.Errorf("Invalid device index: %d", dev)
+	}
+	return c.outputStatus(protocol.RegOutputI2C0 + dev)
+}
+
+// Read the status of a group of outputs
+func (c *Client) outputStatus(reg uint8) (OutputStatus, error) {
+	var r [protocol.OutputStatusSize]uint8
+	if err := c.readBytes(reg, r[:]); err != nil {
+		return OutputStatus{}, fmt.Errorf("Failed to read output status: %w", err)
+	}
+	return OutputStatus{
+		Value:   r[0],
+		Written: r[1]&protocol.OutputFlagWritten != 0,
+		Failed:  r[1]&protocol.OutputFlagFailed != 0,
+		Pending: r[1]&protocol.OutputFlagPending != 0,
+	}, nil
+}
+
+// SetPCFOutputs sets the 8 output pins of the PCF8574 device with given index (0..7).
+// Bit N controls pin N.
+func (c *Client) SetPCFOutputs(dev uint8, bits uint8) error {
+	if dev >= protocol.MaxPCFDevices {
+		return fmt.Errorf("Invalid PCF8574 device index: %d", dev)
+	}
+	if err := c.writeByte(protocol.RegOutputI2C0+dev, bits); err != nil {
+		return fmt.Errorf("Failed to write PCF8574 outputs: %w", err)
+	}
+	return nil
+}
+
+// SetPWM sets the PWM value (0-255, 255 = fully on) of the on-pcb pin with given index (0..7).
+// Once a PWM value has been set, the pin is no longer controlled by SetOutputs,
+// until it is released with ReleasePWM.
+func (c *Client) SetPWM(pin uint8, value uint8) error {
+	if pin >= protocol.IOPinCount {
+		return fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	if err := c.writeByte(protocol.RegConfigurePWM0+pin, value); err != nil {
+		return fmt.Errorf("Failed to write PWM value: %w", err)
+	}
+	return nil
+}
+
+// PWM returns the PWM value (0-255) applied to the on-pcb pin with given index (0..7).
+// Returns 0 if the pin is not in PWM mode.
+func (c *Client) PWM(pin uint8) (uint8, error) {
+	if pin >= protocol.IOPinCount {
+		return 0, fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	value, err := c.readByte(protocol.RegConfigurePWM0 + pin)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read PWM value: %w", err)
+	}
+	return value, nil
+}
+
+// SetPWMDuty sets the PWM duty cycle (0xffff = fully on) of the on-pcb pin
+// with given index (0..7).
+// Once a PWM duty cycle has been set, the pin is no longer controlled by SetOutputs,
+// until it is released with ReleasePWM.
+func (c *Client) SetPWMDuty(pin uint8, duty uint16) error {
+	if pin >= protocol.IOPinCount {
+		return fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	if err := c.writeUint16(protocol.RegPWMDuty0+pin, duty); err != nil {
+		return fmt.Errorf("Failed to write PWM duty cycle: %w", err)
+	}
+	return nil
+}
+
+// PWMDuty returns the PWM duty cycle (0xffff = fully on) of the on-pcb pin
+// with given index (0..7).
+func (c *Client) PWMDuty(pin uint8) (uint16, error) {
+	if pin >= protocol.IOPinCount {
+		return 0, fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	result, err := c.readUint16(protocol.RegPWMDuty0 + pin)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read PWM duty cycle: %w", err)
+	}
+	return result, nil
+}
+
+// SetPWMFrequency sets the PWM frequency in Hz (0 = default) of the on-pcb pin
+// with given index (0..7).
+// Pins on the same PWM slice must use the same frequency, see PWMConflicts.
+func (c *Client) SetPWMFrequency(pin uint8, hz uint16) error {
+	if pin >= protocol.IOPinCount {
+		return fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	if err := c.writeUint16(protocol.RegPWMFrequency0+pin, hz); err != nil {
+		return fmt.Errorf("Failed to write PWM frequency: %w", err)
+	}
+	return nil
+}
+
+// PWMFrequency returns the PWM frequency in Hz (0 = default) of the on-pcb pin
+// with given index (0..7).
+func (c *Client) PWMFrequency(pin uint8) (uint16, error) {
+	if pin >= protocol.IOPinCount {
+		return 0, fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	result, err := c.readUint16(protocol.RegPWMFrequency0 + pin)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read PWM frequency: %w", err)
+	}
+	return result, nil
+}
+
+// PWMPins returns a mask of the on-pcb pins that are in PWM mode.
+// Bit N is set for pin N.
+func (c *Client) PWMPins() (uint8, error) {
+	result, err := c.readByte(protocol.RegPWMRelease)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read PWM pins: %w", err)
+	}
+	return result, nil
+}
+
+// ReleasePWM returns the on-pcb pins in the given mask from PWM mode
+// to digital output, controlled by SetOutputs.
+func (c *Client) ReleasePWM(mask uint8) error {
+	if err := c.writeByte(protocol.RegPWMRelease, mask); err != nil {
+		return fmt.Errorf("Failed to release PWM pins: %w", err)
+	}
+	return nil
+}
+
+// ServoStatus is the status of a servo on an on-pcb pin.
+type ServoStatus struct {
+	// Target position (0-255)
+	Target uint8
+	// Current position (0-255)
+	Position uint8
+	// Set while the servo moves towards its target
+	Moving bool
+	// Set while pulses are sent to the servo
+	Signal bool
+}
+
+// SetServoTarget sets the target position (0-255) of the servo on the on-pcb pin
+// with given index (0..7), switching the pin to servo mode.
+func (c *Client) SetServoTarget(pin uint8, target uint8) error {
+	if pin >= protocol.IOPinCount {
+		return fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	if err := c.writeByte(protocol.RegServoTarget0+pin, target); err != nil {
+		return fmt.Errorf("Failed to write servo target: %w", err)
+	}
+	return nil
+}
+
+// ServoStatus returns the status of the servo on the on-pcb pin with given index (0..7).
+func (c *Client) ServoStatus(pin uint8) (ServoStatus, error) {
+	if pin >= protocol.IOPinCount {
+		return ServoStatus{}, fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	var r [protocol.ServoStatusSize]uint8
+	if err := c.readBytes(protocol.RegServoTarget0+pin, r[:]); err != nil {
+		return ServoStatus{}, fmt.Errorf("Failed to read servo status: %w", err)
+	}
+	return ServoStatus{
+		Target:   r[0],
+		Position: r[1],
+		Moving:   r[2]&protocol.ServoFlagMoving != 0,
+		Signal:   r[2]&protocol.ServoFlagSignal != 0,
+	}, nil
+}
+
+// ServoParams returns the servo parameters of the on-pcb pin with given index (0..7).
+func (c *Client) ServoParams(pin uint8) (config.ServoParams, error) {
+	if pin >= protocol.IOPinCount {
+		return config.ServoParams{}, fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	var r [protocol.ServoRecordSize]uint8
+	if err := c.readBytes(protocol.RegServoConfig0+pin, r[:]); err != nil {
+		return config.ServoParams{}, fmt.Errorf("Failed to read servo parameters: %w", err)
+	}
+	return config.DecodeServoRecord(r[:])
+}
+
+// SetServoParams sets the servo parameters of the on-pcb pin with given index (0..7).
+func (c *Client) SetServoParams(pin uint8, params config.ServoParams) error {
+	if pin >= protocol.IOPinCount {
+		return fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	if err := c.writeBytes(protocol.RegServoConfig0+pin, params.EncodeRecord()); err != nil {
+		return fmt.Errorf("Failed to write servo parameters: %w", err)
+	}
+	return nil
+}
+
+// Effect returns the light effect of the on-pcb pin with given index (0..7).
+// Pins without effect (or driven as partner of an alternating effect) return
+// protocol.EffectNone.
+func (c *Client) Effect(pin uint8) (effects.Params, error) {
+	if pin >= protocol.IOPinCount {
+		return effects.Params{}, fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	var r [protocol.EffectRecordSize]uint8
+	if err := c.readBytes(protocol.RegEffect0+pin, r[:]); err != nil {
+		return effects.Params{}, fmt.Errorf("Failed to read effect: %w", err)
+	}
+	return effects.DecodeRecord(r[:])
+}
+
+// SetEffect starts a light effect on the on-pcb pin with given index (0..7).
+// Use protocol.EffectNone to stop the effect and return the pin to digital output.
+func (c *Client) SetEffect(pin uint8, params effects.Params) error {
+	if pin >= protocol.IOPinCount {
+		return fmt.Errorf("Invalid pin index: %d", pin)
+	}
+	if err := params.Validate(pin); err != nil {
+		return err
+	}
+	if err := c.writeBytes(protocol.RegEffect0+pin, params.EncodeRecord()); err != nil {
+		return fmt.Errorf("Failed to write effect: %w", err)
+	}
+	return nil
+}
+
+// PWMConflicts returns a mask of the on-pcb pins whose last PWM value was
+// rejected, because it conflicts with another pin on the same PWM slice.
+// Bit N is set for pin N.
+func (c *Client) PWMConflicts() (uint8, error) {
+	result, err := c.readByte(protocol.RegPWMConflicts)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read PWM conflicts: %w", err)
+	}
+	return result, nil
+}
+
+// AckPWMConflicts clears the PWM conflicts of the on-pcb pins in the given mask.
+func (c *Client) AckPWMConflicts(mask uint8) error {
+	if err := c.writeByte(protocol.RegPWMConflicts, mask); err != nil {
+		return fmt.Errorf("Failed to acknowledge PWM conflicts: %w", err)
+	}
+	return nil
+}
+
+// Read a single byte register
+func (c *Client) readByte(reg uint8) (uint8, error) {
+	w := [1]uint8{reg}
+	var r [1]uint8
+	if err := c.bus.Tx(uint16(c.address), w[:], r[:]); err != nil {
+		return 0, err
+	}
+	return r[0], nil
+}
+
+// Read a 16-bit register (LSB first)
+func (c *Client) readUint16(reg uint8) (uint16, error) {
+	w := [1]uint8{reg}
+	var r [2]uint8
+	if err := c.bus.Tx(uint16(c.address), w[:], r[:]); err != nil {
+		return 0, err
+	}
+	return uint16(r[0]) | (uint16(r[1]) << 8), nil
+}
+
+// Write a single byte register
+func (c *Client) writeByte(reg uint8, value uint8) error {
+	w := [2]uint8{reg, value}
+	if err := c.bus.Tx(uint16(c.address), w[:], nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Write a 16-bit register (LSB first)
+func (c *Client) writeUint16(reg uint8, value uint16) error {
+	w := [3]uint8{reg, uint8(value), uint8(value >> 8)}
+	if err := c.bus.Tx(uint16(c.address), w[:], nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Read a multi-byte register
+func (c *Client) readBytes(reg uint8, r []byte) error {
+	w := [1]uint8{reg}
+	return c.bus.Tx(uint16(c.address), w[:], r)
+}
+
+// Write a multi-byte register
+func (c *Client) writeBytes(reg uint8, values []byte) error {
+	w := append([]byte{reg}, values...)
+	return c.bus.Tx(uint16(c.address), w, nil)
+}
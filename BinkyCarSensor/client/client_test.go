@@ -0,0 +1,312 @@
+package client_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/client"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/client/fakeboard"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/detection"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus/mockbus"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+const (
+	// I2C address of the fake board
+	testAddress = 0x30
+)
+
+// Create a client connected to a fake board with 8 sensors & 2 PCF8574 devices
+func newTestClient() (*client.Client, *fakeboard.Board) {
+	board := fakeboard.NewBoard(8, 2)
+	bus := mockbus.New()
+	bus.Attach(testAddress, board)
+	return client.New(bus, testAddress), board
+}
+
+func TestReadRegisters(t *testing.T) {
+	c, board := newTestClient()
+	board.SetVersion(1, 2, 3)
+	tests := []struct {
+		name     string
+		read     func() (any, error)
+		expected any
+	}{
+		{"Version", func() (any, error) { return c.Version() }, client.Version{Major: 1, Minor: 2, Patch: 3}},
+		{"SensorCount", func() (any, error) { return c.SensorCount() }, uint8(8)},
+		{"OutputCount", func() (any, error) { return c.OutputCount() }, uint8(16)},
+		{"ProbeInterval", func() (any, error) { return c.ProbeInterval() }, config.DefaultDetection().ProbeInterval},
+		{"PresenceMode", func() (any, error) { return c.PresenceMode() }, uint16(0)},
+		{"Label", func() (any, error) { return c.Label() }, ""},
+	}
+	for _, test := range tests {
+		value, err := test.read()
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+		} else if value != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, value)
+		}
+	}
+	if v, _ := c.Version(); v.String() != "1.2.3" {
+		t.Errorf("expected version 1.2.3, got %s", v)
+	}
+}
+
+func TestWriteRegisters(t *testing.T) {
+	c, _ := newTestClient()
+	params := config.DetectionParams{Lag: 8, Threshold: 600, Influence: 40}
+	detector := config.DetectorParams{Detector: protocol.DetectorHysteresis, OnThreshold: 900, OffThreshold: 300, MinOnTime: 50}
+	calib := config.SensorCalibration{Valid: true, Baseline: 13000, Noise: 7}
+	pair := config.PairParams{First: 2, Second: 3, Spacing: 120}
+	tests := []struct {
+		name     string
+		write    func() error
+		read     func() (any, error)
+		expected any
+	}{
+		{"ProbeInterval",
+			func() error { return c.SetProbeInterval(time.Millisecond * 80) },
+			func() (any, error) { return c.ProbeInterval() },
+			time.Millisecond * 80},
+		{"DetectionParams",
+			func() error { return c.SetDetectionParams(3, params, true) },
+			func() (any, error) { p, _, err := c.DetectionParams(3); return p, err },
+			params},
+		{"Detector",
+			func() error { return c.SetDetector(1, detector) },
+			func() (any, error) { return c.Detector(1) },
+			detector},
+		{"Calibration",
+			func() error { return c.SetCalibration(4, calib) },
+			func() (any, error) { return c.Calibration(4) },
+			calib},
+		{"PresenceMode",
+			func() error { return c.SetPresenceMode(0x0012) },
+			func() (any, error) { return c.PresenceMode() },
+			uint16(0x0012)},
+		{"PairParams",
+			func() error { return c.SetPairParams(1, pair) },
+			func() (any, error) { return c.PairParams(1) },
+			pair},
+		{"Label",
+			func() error { return c.SetLabel("Yard") },
+			func() (any, error) { return c.Label() },
+			"Yard"},
+		{"PWM",
+			func() error { return c.SetPWM(2, 128) },
+			func() (any, error) { return c.PWM(2) },
+			uint8(128)},
+	}
+	for _, test := range tests {
+		if err := test.write(); err != nil {
+			t.Errorf("%s: write failed: %s", test.name, err)
+			continue
+		}
+		value, err := test.read()
+		if err != nil {
+			t.Errorf("%s: read failed: %s", test.name, err)
+		} else if value != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, value)
+		}
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	c, board := newTestClient()
+	if err := c.SetOutputs(0xa5); err != nil {
+		t.Fatalf("SetOutputs failed: %s", err)
+	}
+	if err := c.SetPCFOutputs(1, 0x3c); err != nil {
+		t.Fatalf("SetPCFOutputs failed: %s", err)
+	}
+	if board.Outputs() != 0xa5 || board.PCFOutputs(1) != 0x3c {
+		t.Errorf("expected outputs 0xa5 & 0x3c, got 0x%02x & 0x%02x", board.Outputs(), board.PCFOutputs(1))
+	}
+	if status, err := c.Outputs(); err != nil {
+		t.Errorf("Outputs failed: %s", err)
+	} else if status.Value != 0xa5 || !status.Written || status.Failed {
+		t.Errorf("unexpected output status %+v", status)
+	}
+	if status, err := c.PCFOutputs(1); err != nil {
+		t.Errorf("PCFOutputs failed: %s", err)
+	} else if status.Value != 0x3c || !status.Written {
+		t.Errorf("unexpected PCF8574 output status %+v", status)
+	}
+}
+
+func TestSensorStateAndEdges(t *testing.T) {
+	c, board := newTestClient()
+	board.SetSensorState(0x0005)
+	board.SetSensorState(0x0004)
+
+	steps := []struct {
+		name     string
+		read     func() (uint16, error)
+		expected uint16
+	}{
+		// Latched until read
+		{"SensorState", c.SensorState, 0x0005},
+		{"SensorState after read", c.SensorState, 0x0004},
+		{"RisingEdges", c.RisingEdges, 0x0005},
+		{"FallingEdges", c.FallingEdges, 0x0001},
+		{"PassCount 0", func() (uint16, error) { return c.PassCount(0) }, 1},
+		{"PassCount 2", func() (uint16, error) { return c.PassCount(2) }, 1},
+		{"RisingEdges after ack", func() (uint16, error) {
+			if err := c.AckEdges(0x0001); err != nil {
+				return 0, err
+			}
+			return c.RisingEdges()
+		}, 0x0004},
+		{"FallingEdges after ack", c.FallingEdges, 0},
+		{"PassCount 2 after reset", func() (uint16, error) {
+			if err := c.ResetPassCounts(0x0004); err != nil {
+				return 0, err
+			}
+			return c.PassCount(2)
+		}, 0},
+		{"PassCount 0 after reset", func() (uint16, error) { return c.PassCount(0) }, 1},
+	}
+	for _, step := range steps {
+		if value, err := step.read(); err != nil {
+			t.Errorf("%s: unexpected error %s", step.name, err)
+		} else if value != step.expected {
+			t.Errorf("%s: expected 0x%04x, got 0x%04x", step.name, step.expected, value)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	c, board := newTestClient()
+	board.SetSensorPolarity(0x0002)
+	board.SetSensorState(0x0003)
+	board.SetSensorState(0x0002)
+
+	if count, overflow, err := c.EventStatus(); err != nil {
+		t.Fatalf("EventStatus failed: %s", err)
+	} else if count != 3 || overflow {
+		t.Errorf("expected 3 events without overflow, got %d (overflow=%v)", count, overflow)
+	}
+	events, err := c.Events()
+	if err != nil {
+		t.Fatalf("Events failed: %s", err)
+	}
+	expected := []struct {
+		sensor   uint8
+		active   bool
+		negative bool
+	}{
+		{0, true, false},
+		{1, true, true},
+		{0, false, false},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for idx, e := range expected {
+		if events[idx].Sensor != e.sensor || events[idx].Active != e.active || events[idx].Negative != e.negative {
+			t.Errorf("event %d: expected %+v, got %+v", idx, e, events[idx])
+		}
+	}
+	if _, ok, err := c.NextEvent(); err != nil || ok {
+		t.Errorf("expected empty queue, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestEventsOverflow(t *testing.T) {
+	c, board := newTestClient()
+	// Each toggle queues one event
+	for idx := 0; idx < detection.EventQueueSize+2; idx++ {
+		board.SetSensorState(uint16(idx+1) % 2)
+	}
+	if count, overflow, err := c.EventStatus(); err != nil {
+		t.Fatalf("EventStatus failed: %s", err)
+	} else if count != detection.EventQueueSize || !overflow {
+		t.Errorf("expected full queue with overflow, got %d (overflow=%v)", count, overflow)
+	}
+	events, err := c.Events()
+	if err != nil {
+		t.Fatalf("Events failed: %s", err)
+	}
+	if len(events) != detection.EventQueueSize {
+		t.Fatalf("expected %d events, got %d", detection.EventQueueSize, len(events))
+	}
+	if !events[0].Overflow {
+		t.Error("expected overflow to be reported with the first event")
+	}
+	if _, overflow, _ := c.EventStatus(); overflow {
+		t.Error("expected overflow to be cleared once the queue is drained")
+	}
+}
+
+func TestConfigSave(t *testing.T) {
+	c, board := newTestClient()
+	if version, flags, err := c.ConfigStatus(); err != nil {
+		t.Fatalf("ConfigStatus failed: %s", err)
+	} else if version != 0 || flags != 0 {
+		t.Errorf("expected no saved configuration, got version %d flags 0x%02x", version, flags)
+	}
+	if err := c.SetLabel("Depot"); err != nil {
+		t.Fatalf("SetLabel failed: %s", err)
+	}
+	if _, flags, _ := c.ConfigStatus(); flags != protocol.ConfigFlagModified {
+		t.Errorf("expected modified flag, got 0x%02x", flags)
+	}
+	if err := c.SaveConfig(); err != nil {
+		t.Fatalf("SaveConfig failed: %s", err)
+	}
+	if saved, ok := board.SavedConfig(); !ok || saved.Label != "Depot" {
+		t.Errorf("expected saved label, got %q (saved=%v)", saved.Label, ok)
+	}
+	if version, flags, _ := c.ConfigStatus(); version != config.CurrentVersion || flags != protocol.ConfigFlagLoaded {
+		t.Errorf("expected saved configuration, got version %d flags 0x%02x", version, flags)
+	}
+	if err := c.SetPWM(2, 128); err != nil {
+		t.Fatalf("SetPWM failed: %s", err)
+	}
+	if err := c.FactoryReset(); err != nil {
+		t.Fatalf("FactoryReset failed: %s", err)
+	}
+	if label, _ := c.Label(); label != "" {
+		t.Errorf("expected empty label after factory reset, got %q", label)
+	}
+	if _, ok := board.SavedConfig(); ok {
+		t.Error("expected no saved configuration after factory reset")
+	}
+	if _, isPWM := board.PWM(2); isPWM {
+		t.Error("expected PWM pin to return to digital output after factory reset")
+	}
+}
+
+func TestInvalidArguments(t *testing.T) {
+	c, board := newTestClient()
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"PassCount", func() error { _, err := c.PassCount(protocol.MaxSensorCount); return err }},
+		{"SetPCFOutputs", func() error { return c.SetPCFOutputs(protocol.MaxPCFDevices, 0) }},
+		{"SetPWM", func() error { return c.SetPWM(protocol.IOPinCount, 0) }},
+		{"SetLabel", func() error { return c.SetLabel(string(make([]byte, protocol.LabelMaxSize+1))) }},
+		{"SetDetectionParams", func() error { return c.SetDetectionParams(0, config.DetectionParams{}, true) }},
+	}
+	for _, test := range tests {
+		if err := test.call(); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+	if board.TxCount() != 0 {
+		t.Errorf("expected invalid arguments to be rejected without transactions, got %d", board.TxCount())
+	}
+}
+
+func TestMissingBoard(t *testing.T) {
+	c := client.New(mockbus.New(), testAddress)
+	if _, err := c.SensorState(); !errors.Is(err, mockbus.ErrNACK) {
+		t.Errorf("expected ErrNACK, got %v", err)
+	}
+	if err := c.SetOutputs(1); !errors.Is(err, mockbus.ErrNACK) {
+		t.Errorf("expected ErrNACK, got %v", err)
+	}
+}
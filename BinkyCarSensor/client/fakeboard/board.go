@@ -0,0 +1,742 @@
+// Package fakeboard implements an in-memory BinkyCarSensor board,
+// so code using the client package can be tested without hardware.
+//
+// Attach a Board to a mockbus.Bus at the address of the board:
+//
+//	bus := mockbus.New()
+//	board := fakeboard.NewBoard(8, 1)
+//	bus.Attach(uint16(protocol.DefaultI2CAddress), board)
+//	c := client.New(bus, protocol.DefaultI2CAddress)
+package fakeboard
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/client"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/detection"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/effects"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/inputs"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pairs"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pulse"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pwmslices"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/servo"
+)
+
+// Board simulates the register behavior of a single BinkyCarSensor board.
+type Board struct {
+	mutex sync.Mutex
+
+	version      [3]uint8
+	sensorCount  uint8
+	outputCount  uint8
+	sensorState  detection.State
+	pairs        pairs.Tracker
+	config       config.Config
+	configFlags  uint8
+	selected     uint8 // Selected sensor for detection parameters
+	savedConfig  *config.Config
+	start        time.Time
+	outputs      uint8
+	pcfOutputs   [protocol.MaxPCFDevices]uint8
+	pcfApplied   [protocol.MaxPCFDevices]uint8
+	pcfFlags     [protocol.MaxPCFDevices]uint8
+	pcfFailing   [protocol.MaxPCFDevices]bool
+	outputGroups [1 + protocol.MaxPCFDevices]pulse.Group
+	pulseOutput  uint8 // Selected output for pulse settings
+	isPWM        [protocol.IOPinCount]bool
+	pwmDuty      [protocol.IOPinCount]uint16
+	pwmFrequency [protocol.IOPinCount]uint16
+	pwmSlices    *pwmslices.Allocator
+	pwmConflicts uint8
+	isServo      [protocol.IOPinCount]bool
+	servos       [protocol.IOPinCount]*servo.Servo
+	isEffect     [protocol.IOPinCount]bool
+	effects      [protocol.IOPinCount]*effects.Effect
+	isInput      [protocol.IOPinCount]bool
+	digitalModes [protocol.IOPinCount]uint8
+	pinErrors    uint8
+	lastPinError uint8
+	io0Locked    bool
+	inputLevels  uint8 // Level of the on-pcb pins (bit set when high)
+	inputs       inputs.Group
+	inputState   detection.State
+	busStats     map[uint8]client.BusStats
+	busDevice    uint8 // Selected device for bus statistics
+	txCount      int
+	lastTx       time.Time
+	failsafe     uint8 // Failsafe status flags
+	resetStatus  client.ResetStatus
+	idleLevels   [protocol.MaxSensorCount]config.SensorCalibration // Result of a calibration
+	calibSensor  uint8                                             // Selected sensor for calibration
+	detSensor    uint8                                             // Selected sensor for detector
+}
+
+// NewBoard initializes a new board with given number of sensors and PCF8574 devices.
+func NewBoard(sensorCount, pcfDeviceCount uint8) *Board {
+	b := &Board{
+		version:     [3]uint8{0, 1, 0},
+		sensorCount: sensorCount,
+		outputCount: pcfDeviceCount * 8,
+		start:       time.Now(),
+		config:      config.Default(),
+		selected:    protocol.DetectionGlobal,
+		pwmSlices:   pwmslices.New(protocol.IOPinGPIOs),
+	}
+	for pin := range b.servos {
+		b.servos[pin] = servo.New(b.config.Servos[pin])
+	}
+	b.configurePulses()
+	return b
+}
+
+// SetVersion sets the firmware version reported by the board.
+func (b *Board) SetVersion(major, minor, patch uint8) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.version = [3]uint8{major, minor, patch}
+}
+
+// SetSensorCount sets the number of sensors reported by the board.
+// Like the firmware, a board without ADS1115 devices reports 0 sensors
+// until devices are detected.
+func (b *Board) SetSensorCount(count uint8) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.sensorCount = count
+}
+
+// SetSensorState sets the current state of all sensors.
+// Like the firmware, sensors that become active are latched until
+// the state register is read, and sensor pairs are timed.
+func (b *Board) SetSensorState(x uint16) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := b.millisSinceStart()
+	b.sensorState.Update(x, now)
+	b.pairs.Update(x, now)
+}
+
+// SetSensorPolarity sets the polarity of the detections of all sensors
+// (bit N is set when the output of sensor N is below its baseline).
+// Set it before the sensors become active, so it is included in their events.
+func (b *Board) SetSensorPolarity(x uint16) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.sensorState.SetPolarity(x)
+}
+
+// SetBusStats sets the statistics reported for the device with given
+// I2C address on the I2C bus of the board.
+func (b *Board) SetBusStats(deviceAddress uint8, transactions, errors uint32) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.busStats == nil {
+		b.busStats = make(map[uint8]client.BusStats)
+	}
+	b.busStats[deviceAddress] = client.BusStats{
+		Address:      deviceAddress,
+		Transactions: transactions,
+		Errors:       errors,
+	}
+}
+
+// SetResetStatus sets the cause of the last reset reported by the board.
+func (b *Board) SetResetStatus(status client.ResetStatus) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.resetStatus = status
+}
+
+// Outputs returns the last value written to the on-pcb output pins.
+func (b *Board) Outputs() uint8 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.outputs
+}
+
+// PCFOutputs returns the last value written to the PCF8574 device with given index.
+func (b *Board) PCFOutputs(dev uint8) uint8 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if int(dev) >= len(b.pcfOutputs) {
+		return 0
+	}
+	return b.pcfOutputs[dev]
+}
+
+// PWM returns the PWM value (0-255) of the on-pcb pin with given index
+// and true if the pin is in PWM mode.
+func (b *Board) PWM(pin uint8) (uint8, bool) {
+	duty, isPWM := b.PWMDuty(pin)
+	return uint8(duty >> 8), isPWM
+}
+
+// PWMDuty returns the PWM duty cycle (0xffff = fully on) of the on-pcb pin
+// with given index and true if the pin is in PWM mode.
+func (b *Board) PWMDuty(pin uint8) (uint16, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if int(pin) >= len(b.pwmDuty) {
+		return 0, false
+	}
+	return b.pwmDuty[pin], b.isPWM[pin]
+}
+
+// PWMFrequency returns the PWM frequency in Hz (0 = default) of the on-pcb pin
+// with given index.
+func (b *Board) PWMFrequency(pin uint8) uint16 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if int(pin) >= len(b.pwmFrequency) {
+		return 0
+	}
+	return b.pwmFrequency[pin]
+}
+
+// Servo returns the current position of the servo on the on-pcb pin with
+// given index and true if the pin is in servo mode.
+func (b *Board) Servo(pin uint8) (uint8, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if int(pin) >= len(b.servos) {
+		return 0, false
+	}
+	b.updateServos()
+	return b.servos[pin].Position(), b.isServo[pin]
+}
+
+// Config returns the current configuration.
+func (b *Board) Config() config.Config {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.config
+}
+
+// SavedConfig returns the configuration that was last saved
+// and true, or false if it was never saved (or erased by a factory reset).
+func (b *Board) SavedConfig() (config.Config, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.savedConfig == nil {
+		return config.Config{}, false
+	}
+	return *b.savedConfig, true
+}
+
+// Update the configuration if it is valid
+func (b *Board) updateConfig(update config.Config) {
+	if update.Validate() == nil {
+		b.config = update
+		b.configFlags |= protocol.ConfigFlagModified
+	}
+}
+
+// TxCount returns the number of transactions handled by this board.
+func (b *Board) TxCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.txCount
+}
+
+// Tx handles a single I2C transaction targeting this board.
+func (b *Board) Tx(w, r []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.txCount++
+	b.updateServos()
+	b.updateEffects()
+	b.updatePulses()
+	b.updateInputs()
+	b.checkFailsafe()
+	if len(w) == 0 {
+		return fmt.Errorf("Missing register")
+	}
+	reg := w[0]
+	if len(w) >= 2 {
+		b.receive(reg, w[1:])
+	}
+	if len(r) > 0 {
+		b.request(reg, r)
+	}
+	return nil
+}
+
+// Handle a register write
+func (b *Board) receive(reg uint8, values []uint8) {
+	value := values[0]
+	switch {
+	case reg == protocol.RegDetectionParams:
+		b.selected = value
+		if len(values) > 1 {
+			update := b.config
+			if update.Detection.SetRecord(value, values[1:]) == nil {
+				b.updateConfig(update)
+			}
+		}
+	case reg == protocol.RegCalibration:
+		b.calibSensor = value
+		if len(values) > 1 && value < protocol.MaxSensorCount {
+			if c, err := config.DecodeCalibrationRecord(values[1:]); err == nil {
+				update := b.config
+				update.Detection.Calibration[value] = c
+				b.updateConfig(update)
+			}
+		}
+	case reg == protocol.RegCalibrate:
+		b.calibrate(uint16Value(values))
+	case reg == protocol.RegPresenceMode:
+		update := b.config
+		update.Detection.Presence = uint16Value(values)
+		b.updateConfig(update)
+	case reg == protocol.RegDetector:
+		b.detSensor = value
+		if len(values) > 1 && value < protocol.MaxSensorCount {
+			if params, err := config.DecodeDetectorRecord(values[1:]); err == nil {
+				update := b.config
+				update.Detection.Detectors[value] = params
+				b.updateConfig(update)
+			}
+		}
+	case reg == protocol.RegCalibrationMode:
+		update := b.config
+		update.Detection.CalibrateAtBoot = value&protocol.CalibrationModeAtBoot != 0
+		b.updateConfig(update)
+	case reg == protocol.RegProbeInterval:
+		update := b.config
+		update.Detection.ProbeInterval = time.Duration(uint16Value(values)) * time.Millisecond
+		b.updateConfig(update)
+	case reg == protocol.RegConfigI2CAddress:
+		update := b.config
+		update.I2CAddress = value
+		b.updateConfig(update)
+	case reg == protocol.RegConfigPinModes:
+		update := b.config
+		copy(update.PinModes[:], values)
+		b.updateConfig(update)
+	case reg == protocol.RegConfigOutputDefaults:
+		update := b.config
+		update.OutputDefaults = value
+		copy(update.PCFOutputDefaults[:], values[1:])
+		b.updateConfig(update)
+	case reg == protocol.RegConfigPWMDefaults:
+		update := b.config
+		copy(update.PWMDefaults[:], values)
+		b.updateConfig(update)
+	case reg == protocol.RegConfigLabel:
+		update := b.config
+		update.Label = strings.TrimRight(string(values), "\x00")
+		b.updateConfig(update)
+	case reg == protocol.RegSaveConfig:
+		if value == protocol.ConfigSaveMagic {
+			saved := b.config
+			b.savedConfig = &saved
+			b.configFlags = protocol.ConfigFlagLoaded
+		}
+	case reg == protocol.RegFactoryReset:
+		if value == protocol.FactoryResetMagic {
+			b.config = config.Default()
+			// Like the firmware, all pins return to their power-on mode & value
+			for pin := range b.isPWM {
+				b.releasePWM(uint8(pin))
+			}
+			for pin, s := range b.servos {
+				s.Configure(b.config.Servos[pin])
+			}
+			b.configurePulses()
+			b.configureInputs()
+			b.configurePairs()
+			for pin, mode := range b.config.PinModes {
+				b.setPinMode(uint8(pin), mode)
+			}
+			b.outputs = b.outputGroups[0].Set(b.config.OutputDefaults, b.millisSinceStart())
+			b.savedConfig = nil
+			b.configFlags = 0
+		}
+	case reg == protocol.RegFailsafeStatus:
+		b.failsafe &^= protocol.FailsafeFlagTriggered
+	case reg == protocol.RegFailsafeTimeout:
+		update := b.config
+		update.Failsafe.Timeout = time.Duration(uint16Value(values)) * time.Millisecond
+		b.updateConfig(update)
+	case reg == protocol.RegFailsafeOutputs:
+		update := b.config
+		update.Failsafe.Outputs = value
+		copy(update.Failsafe.PCFOutputs[:], values[1:])
+		b.updateConfig(update)
+	case reg == protocol.RegFailsafePWM:
+		update := b.config
+		copy(update.Failsafe.PWM[:], values)
+		b.updateConfig(update)
+	case reg == protocol.RegBusStats:
+		b.busDevice = value
+	case reg == protocol.RegBusResetStats:
+		b.busStats = nil
+	case reg == protocol.RegCarSensorAckEdges:
+		b.sensorState.AckEdges(uint16Value(values))
+	case reg == protocol.RegCarSensorResetPassCounts:
+		b.sensorState.ResetPassCounts(uint16Value(values))
+	case reg == protocol.RegOutput:
+		if value&0x01 != 0 && b.io0Locked && b.pinMode(0) == protocol.PinModeOutput {
+			b.rejectPin(0, protocol.PinErrorAddressStrap)
+		}
+		for i := uint8(0); i < protocol.IOPinCount; i++ {
+			// Pins in PWM or input mode are not affected
+			if b.isPWM[i] || b.isInput[i] {
+				value = (value &^ (1 << i)) | (b.outputs & (1 << i))
+			}
+		}
+		b.outputs = b.outputGroups[0].Set(value, b.millisSinceStart())
+	case reg >= protocol.RegOutputI2C0 && reg <= protocol.RegOutputI2C7:
+		dev := reg - protocol.RegOutputI2C0
+		b.writePCF(dev, b.outputGroups[1+dev].Set(value, b.millisSinceStart()))
+	case reg == protocol.RegPulseConfig:
+		b.pulseOutput = value
+		if len(values) > 1 && value < protocol.PulseOutputCount {
+			if params, err := config.DecodePulseRecord(values[1:]); err == nil {
+				update := b.config
+				update.Pulses[value] = params
+				b.updateConfig(update)
+				b.configurePulses()
+			}
+		}
+	case reg >= protocol.RegInputConfig0 && reg <= protocol.RegInputConfig7:
+		pin := reg - protocol.RegInputConfig0
+		if params, err := config.DecodeInputRecord(values); err == nil {
+			update := b.config
+			update.Inputs[pin] = params
+			if update.Validate() == nil {
+				b.updateConfig(update)
+				b.setInput(pin, params)
+			}
+		}
+	case reg >= protocol.RegPairConfig0 && reg <= protocol.RegPairConfig3:
+		pair := reg - protocol.RegPairConfig0
+		if params, err := config.DecodePairRecord(values); err == nil {
+			update := b.config
+			update.Pairs[pair] = params
+			if update.Validate() == nil {
+				b.updateConfig(update)
+				b.pairs.Configure(int(pair), params)
+			}
+		}
+	case reg == protocol.RegInputAckEdges:
+		b.inputState.AckEdges(uint16(value))
+	case reg == protocol.RegPulseTripped:
+		for idx := 0; idx < len(values) && idx < len(b.outputGroups); idx++ {
+			b.outputGroups[idx].AckTripped(values[idx])
+		}
+	case reg >= protocol.RegConfigurePWM0 && reg <= protocol.RegConfigurePWM7:
+		b.setPWM(reg-protocol.RegConfigurePWM0, pwmslices.DutyOfByte(value))
+	case reg >= protocol.RegPWMDuty0 && reg <= protocol.RegPWMDuty7:
+		if len(values) >= 2 {
+			b.setPWM(reg-protocol.RegPWMDuty0, uint16Value(values))
+		}
+	case reg >= protocol.RegPWMFrequency0 && reg <= protocol.RegPWMFrequency7:
+		if len(values) >= 2 {
+			pin := reg - protocol.RegPWMFrequency0
+			hz := uint16Value(values)
+			if !b.isServo[pin] && (!b.isPWM[pin] || b.requestPWM(pin, hz)) {
+				b.pwmFrequency[pin] = hz
+			}
+		}
+	case reg == protocol.RegPWMRelease:
+		for pin := uint8(0); pin < protocol.IOPinCount; pin++ {
+			if value&(1<<pin) != 0 {
+				b.releasePWM(pin)
+			}
+		}
+	case reg == protocol.RegPWMConflicts:
+		b.pwmConflicts &^= value
+	case reg >= protocol.RegServoTarget0 && reg <= protocol.RegServoTarget7:
+		b.setServoTarget(reg-protocol.RegServoTarget0, value)
+	case reg >= protocol.RegPinMode0 && reg <= protocol.RegPinMode7:
+		b.setPinMode(reg-protocol.RegPinMode0, value)
+	case reg == protocol.RegPinErrors:
+		b.pinErrors &^= value
+		if b.pinErrors == 0 {
+			b.lastPinError = protocol.PinErrorNone
+		}
+	case reg >= protocol.RegServoConfig0 && reg <= protocol.RegServoConfig7:
+		pin := reg - protocol.RegServoConfig0
+		if params, err := config.DecodeServoRecord(values); err == nil {
+			update := b.config
+			update.Servos[pin] = params
+			b.updateConfig(update)
+			b.servos[pin].Configure(b.config.Servos[pin])
+		}
+	case reg >= protocol.RegEffect0 && reg <= protocol.RegEffect7:
+		pin := reg - protocol.RegEffect0
+		if params, err := effects.DecodeRecord(values); err == nil && params.Validate(pin) == nil {
+			b.setEffect(pin, params)
+		}
+	}
+}
+
+// Handle a register read
+func (b *Board) request(reg uint8, r []byte) {
+	var reply []byte
+	switch reg {
+	case protocol.RegVersionMajor:
+		reply = b.version[0:1]
+	case protocol.RegVersionMinor:
+		reply = b.version[1:2]
+	case protocol.RegVersionPatch:
+		reply = b.version[2:3]
+	case protocol.RegCarSensorCount:
+		reply = []byte{b.sensorCount}
+	case protocol.RegI2COutputCount:
+		reply = []byte{b.outputCount}
+	case protocol.RegOutput:
+		reply = []byte{b.appliedOutputs(), protocol.OutputFlagWritten}
+	case protocol.RegOutputI2C0, protocol.RegOutputI2C1, protocol.RegOutputI2C2, protocol.RegOutputI2C3,
+		protocol.RegOutputI2C4, protocol.RegOutputI2C5, protocol.RegOutputI2C6, protocol.RegOutputI2C7:
+		dev := reg - protocol.RegOutputI2C0
+		reply = []byte{b.pcfApplied[dev], b.pcfFlags[dev]}
+	case protocol.RegCarSensorState:
+		// Reply & reset detections
+		reply = uint16Reply(b.sensorState.ReadLatch())
+	case protocol.RegCarSensorRisingEdges:
+		reply = uint16Reply(b.sensorState.RisingEdges())
+	case protocol.RegCarSensorFallingEdges:
+		reply = uint16Reply(b.sensorState.FallingEdges())
+	case protocol.RegCarSensorPolarity:
+		reply = uint16Reply(b.sensorState.Polarity())
+	case protocol.RegDetectionParams:
+		reply = b.config.Detection.Record(b.selected)
+	case protocol.RegProbeInterval:
+		reply = uint16Reply(uint16(b.config.Detection.ProbeInterval / time.Millisecond))
+	case protocol.RegCalibration:
+		c := config.SensorCalibration{}
+		if b.calibSensor < protocol.MaxSensorCount {
+			c = b.config.Detection.Calibration[b.calibSensor]
+		}
+		reply = c.EncodeRecord()
+	case protocol.RegCalibrate:
+		// Calibration completes immediately
+		reply = uint16Reply(0)
+	case protocol.RegPresenceMode:
+		reply = uint16Reply(b.config.Detection.Presence)
+	case protocol.RegDetector:
+		params := config.DetectorParams{}
+		if b.detSensor < protocol.MaxSensorCount {
+			params = b.config.Detection.Detectors[b.detSensor]
+		}
+		reply = params.EncodeRecord()
+	case protocol.RegCalibrationMode:
+		mode := uint8(0)
+		if b.config.Detection.CalibrateAtBoot {
+			mode |= protocol.CalibrationModeAtBoot
+		}
+		reply = []byte{mode}
+	case protocol.RegConfigStatus:
+		version := uint8(0)
+		if b.savedConfig != nil {
+			version = config.CurrentVersion
+		}
+		reply = []byte{version, b.configFlags}
+	case protocol.RegConfigI2CAddress:
+		reply = []byte{b.config.I2CAddress}
+	case protocol.RegConfigPinModes:
+		reply = b.config.PinModes[:]
+	case protocol.RegConfigOutputDefaults:
+		reply = append([]byte{b.config.OutputDefaults}, b.config.PCFOutputDefaults[:]...)
+	case protocol.RegConfigPWMDefaults:
+		reply = b.config.PWMDefaults[:]
+	case protocol.RegConfigLabel:
+		var label [protocol.LabelMaxSize]byte
+		copy(label[:], b.config.Label)
+		reply = label[:]
+	case protocol.RegCarSensorEventStatus:
+		reply = b.sensorState.Events().StatusRecord()
+	case protocol.RegCarSensorEvent:
+		// Reply & remove event from queue
+		reply = b.sensorState.Events().PopRecord()
+	case protocol.RegPWMConflicts:
+		reply = []byte{b.pwmConflicts}
+	case protocol.RegPulseConfig:
+		if b.pulseOutput < protocol.PulseOutputCount {
+			reply = b.config.Pulses[b.pulseOutput].EncodeRecord()
+		}
+	case protocol.RegPulseTripped:
+		for idx := range b.outputGroups {
+			reply = append(reply, b.outputGroups[idx].Tripped())
+		}
+	case protocol.RegPWMRelease:
+		pwmPins := uint8(0)
+		for pin, isPWM := range b.isPWM {
+			if isPWM {
+				pwmPins |= 1 << pin
+			}
+		}
+		reply = []byte{pwmPins}
+	case protocol.RegFailsafeStatus:
+		reply = []byte{b.failsafe}
+	case protocol.RegPinErrors:
+		reply = []byte{b.pinErrors, b.lastPinError}
+	case protocol.RegInputState:
+		// Reply & reset latched inputs
+		reply = []byte{uint8(b.inputState.ReadLatch())}
+	case protocol.RegInputRisingEdges:
+		reply = []byte{uint8(b.inputState.RisingEdges())}
+	case protocol.RegInputFallingEdges:
+		reply = []byte{uint8(b.inputState.FallingEdges())}
+	case protocol.RegResetStatus:
+		reply = []byte{b.resetStatus.Cause, b.resetStatus.StalledLoops}
+	case protocol.RegFailsafeTimeout:
+		reply = uint16Reply(uint16(b.config.Failsafe.Timeout / time.Millisecond))
+	case protocol.RegFailsafeOutputs:
+		reply = append([]byte{b.config.Failsafe.Outputs}, b.config.Failsafe.PCFOutputs[:]...)
+	case protocol.RegFailsafePWM:
+		reply = b.config.Failsafe.PWM[:]
+	case protocol.RegBusStats:
+		s := b.busStats[b.busDevice]
+		reply = []byte{
+			b.busDevice,
+			uint8(s.Transactions), uint8(s.Transactions >> 8), uint8(s.Transactions >> 16), uint8(s.Transactions >> 24),
+			uint8(s.Errors), uint8(s.Errors >> 8), uint8(s.Errors >> 16), uint8(s.Errors >> 24),
+		}
+	default:
+		if reg >= protocol.RegCarSensorPassCount0 && reg <= protocol.RegCarSensorPassCount15 {
+			reply = uint16Reply(b.sensorState.PassCount(int(reg - protocol.RegCarSensorPassCount0)))
+		} else if reg >= protocol.RegEffect0 && reg <= protocol.RegEffect7 {
+			var params effects.Params
+			if e := b.effects[reg-protocol.RegEffect0]; e != nil {
+				params = e.Params()
+			}
+			reply = params.EncodeRecord()
+		} else if reg >= protocol.RegServoTarget0 && reg <= protocol.RegServoTarget7 {
+			reply = b.servos[reg-protocol.RegServoTarget0].StatusRecord()
+		} else if reg >= protocol.RegConfigurePWM0 && reg <= protocol.RegConfigurePWM7 {
+			value := uint8(0)
+			if pin := reg - protocol.RegConfigurePWM0; b.isPWM[pin] {
+				value = uint8(b.pwmDuty[pin] >> 8)
+			}
+			reply = []byte{value}
+		} else if reg >= protocol.RegPinMode0 && reg <= protocol.RegPinMode7 {
+			reply = []byte{b.pinMode(reg - protocol.RegPinMode0)}
+		} else if reg >= protocol.RegInputConfig0 && reg <= protocol.RegInputConfig7 {
+			reply = b.config.Inputs[reg-protocol.RegInputConfig0].EncodeRecord()
+		} else if reg >= protocol.RegPairConfig0 && reg <= protocol.RegPairConfig3 {
+			reply = b.config.Pairs[reg-protocol.RegPairConfig0].EncodeRecord()
+		} else if reg >= protocol.RegPairResult0 && reg <= protocol.RegPairResult3 {
+			// Reply & mark measurement as read
+			reply = b.pairs.ReadRecord(int(reg - protocol.RegPairResult0))
+		} else if reg >= protocol.RegServoConfig0 && reg <= protocol.RegServoConfig7 {
+			reply = b.config.Servos[reg-protocol.RegServoConfig0].EncodeRecord()
+		} else if reg >= protocol.RegPWMDuty0 && reg <= protocol.RegPWMDuty7 {
+			reply = uint16Reply(b.pwmDuty[reg-protocol.RegPWMDuty0])
+		} else if reg >= protocol.RegPWMFrequency0 && reg <= protocol.RegPWMFrequency7 {
+			reply = uint16Reply(b.pwmFrequency[reg-protocol.RegPWMFrequency0])
+		} else {
+			reply = []byte{0xff, 0xff}
+		}
+	}
+	n := copy(r, reply)
+	for i := n; i < len(r); i++ {
+		r[i] = 0xff
+	}
+}
+
+// Set the PWM duty cycle of a pin, switching it to PWM mode
+func (b *Board) setPWM(pin uint8, duty uint16) {
+	if b.requestPWM(pin, b.pwmFrequency[pin]) {
+		b.isPWM[pin] = true
+		b.isServo[pin] = false
+		b.pwmDuty[pin] = duty
+		b.clearEffect(pin)
+	}
+}
+
+// Set the target position of the servo on a pin, switching it to servo mode
+func (b *Board) setServoTarget(pin, target uint8) {
+	if b.isServo[pin] || b.requestPWM(pin, servo.Frequency) {
+		b.isPWM[pin] = true
+		b.isServo[pin] = true
+		b.pwmFrequency[pin] = servo.Frequency
+		b.clearEffect(pin)
+		b.servos[pin].SetTarget(target, b.millisSinceStart())
+		b.updateServos()
+	}
+}
+
+// Return a pin from PWM mode to digital output
+func (b *Board) releasePWM(pin uint8) {
+	if !b.isPWM[pin] {
+		return
+	}
+	b.pwmSlices.Release(pin)
+	b.isPWM[pin] = false
+	b.isServo[pin] = false
+	b.pwmDuty[pin] = 0
+	b.clearEffect(pin)
+}
+
+// Move all servos towards their targets
+func (b *Board) updateServos() {
+	now := b.millisSinceStart()
+	for pin, isServo := range b.isServo {
+		if isServo {
+			pulse, signal := b.servos[pin].Update(now)
+			b.pwmDuty[pin] = 0
+			if signal {
+				b.pwmDuty[pin] = servo.Duty(pulse)
+			}
+		}
+	}
+}
+
+// Configure the pulse & safety settings of all outputs from the configuration
+func (b *Board) configurePulses() {
+	for idx, params := range b.config.Pulses {
+		b.outputGroups[idx/8].Configure(uint8(idx%8), params)
+	}
+}
+
+// Release outputs whose pulse ended or that exceeded their maximum on-time
+func (b *Board) updatePulses() {
+	now := b.millisSinceStart()
+	b.outputs, _ = b.outputGroups[0].Update(now)
+	for dev := range b.pcfOutputs {
+		if value, changed := b.outputGroups[1+dev].Update(now); changed {
+			b.writePCF(uint8(dev), value)
+		}
+	}
+}
+
+// Returns the number of milliseconds since the board was created
+func (b *Board) millisSinceStart() uint32 {
+	return uint32(time.Since(b.start).Milliseconds())
+}
+
+// Claim the PWM channel of a pin with given frequency, recording conflicts.
+// Returns true on success.
+func (b *Board) requestPWM(pin uint8, hz uint16) bool {
+	if !b.isPWM[pin] && !b.canDrive(pin) {
+		return false
+	}
+	if _, _, err := b.pwmSlices.Request(pin, pwmslices.PeriodOfFrequency(hz)); err != nil {
+		b.pwmConflicts |= 1 << pin
+		return false
+	}
+	b.pwmConflicts &^= 1 << pin
+	return true
+}
+
+// Returns the first 2 bytes of the given values as uint16 (LSB first)
+func uint16Value(values []uint8) uint16 {
+	result := uint16(values[0])
+	if len(values) >= 2 {
+		result |= uint16(values[1]) << 8
+	}
+	return result
+}
+
+// Returns the given value as 2 byte reply (LSB first)
+func uint16Reply(value uint16) []byte {
+	return []byte{uint8(value), uint8(value >> 8)}
+}
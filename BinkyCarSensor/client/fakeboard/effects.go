@@ -0,0 +1,81 @@
+package fakeboard
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/effects"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Effect returns the parameters of the light effect on the on-pcb pin with
+// given index and true if the pin is driven by an effect
+// (possibly as partner of an alternating effect).
+func (b *Board) Effect(pin uint8) (effects.Params, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if int(pin) >= len(b.effects) {
+		return effects.Params{}, false
+	}
+	var params effects.Params
+	if e := b.effects[pin]; e != nil {
+		params = e.Params()
+	}
+	return params, b.isEffect[pin]
+}
+
+// Start a light effect on a pin, like the firmware does
+func (b *Board) setEffect(pin uint8, params effects.Params) {
+	if params.Kind == protocol.EffectNone {
+		b.releasePWM(pin)
+		return
+	}
+	if !b.claimEffectPin(pin) {
+		return
+	}
+	if params.Kind == protocol.EffectAlternate {
+		if !b.claimEffectPin(params.Partner) {
+			b.releasePWM(pin)
+			return
+		}
+	}
+	b.effects[pin] = effects.New(params, b.millisSinceStart(), uint32(pin)+1)
+	b.updateEffects()
+}
+
+// Switch a pin to PWM mode for use by an effect
+func (b *Board) claimEffectPin(pin uint8) bool {
+	b.clearEffect(pin)
+	if !b.requestPWM(pin, b.pwmFrequency[pin]) {
+		return false
+	}
+	b.isPWM[pin] = true
+	b.isServo[pin] = false
+	b.isEffect[pin] = true
+	b.pwmDuty[pin] = 0
+	return true
+}
+
+// Stop the light effect of a pin (if any)
+func (b *Board) clearEffect(pin uint8) {
+	e := b.effects[pin]
+	b.isEffect[pin] = false
+	b.effects[pin] = nil
+	if e != nil && e.Params().Kind == protocol.EffectAlternate {
+		partner := e.Params().Partner
+		if b.isEffect[partner] && b.effects[partner] == nil {
+			b.releasePWM(partner)
+		}
+	}
+}
+
+// Update the duty cycles of all pins driven by effects
+func (b *Board) updateEffects() {
+	now := b.millisSinceStart()
+	for pin, e := range b.effects {
+		if e == nil {
+			continue
+		}
+		b.pwmDuty[pin] = effects.Duty(e.Level(now))
+		if partner := e.Params().Partner; e.Params().Kind == protocol.EffectAlternate && b.isEffect[partner] && b.effects[partner] == nil {
+			b.pwmDuty[partner] = effects.Duty(e.PartnerLevel(now))
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package fakeboard
+
+import (
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pwmslices"
+)
+
+// Drive all outputs to their safe state if the time since the previous
+// transaction exceeds the failsafe timeout.
+// Since the current transaction resumes communication, the failsafe is
+// reported as triggered but not as active.
+func (b *Board) checkFailsafe() {
+	now := time.Now()
+	last := b.lastTx
+	b.lastTx = now
+	timeout := b.config.Failsafe.Timeout
+	if timeout == 0 || last.IsZero() || now.Sub(last) < timeout {
+		return
+	}
+	b.failsafe |= protocol.FailsafeFlagTriggered
+
+	fs := b.config.Failsafe
+	for pin, isPWM := range b.isPWM {
+		if !isPWM {
+			continue
+		}
+		if b.isServo[pin] {
+			b.servos[pin].SetTarget(fs.PWM[pin], b.millisSinceStart())
+		} else {
+			b.setPWM(uint8(pin), pwmslices.DutyOfByte(fs.PWM[pin]))
+		}
+	}
+	b.updateServos()
+	value := fs.Outputs
+	for i := uint8(0); i < protocol.IOPinCount; i++ {
+		// Pins in PWM or input mode are not affected
+		if b.isPWM[i] || b.isInput[i] {
+			value = (value &^ (1 << i)) | (b.outputs & (1 << i))
+		}
+	}
+	b.outputs = b.outputGroups[0].Set(value, b.millisSinceStart())
+	for dev, value := range fs.PCFOutputs {
+		b.writePCF(uint8(dev), b.outputGroups[1+dev].Set(value, b.millisSinceStart()))
+	}
+}
@@ -0,0 +1,44 @@
+package fakeboard
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+)
+
+// SetInputLevels sets the level of the on-pcb pins (bit N is set when pin N is high).
+// Like the firmware, only pins in input mode are sampled & debounced.
+func (b *Board) SetInputLevels(levels uint8) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.inputLevels = levels
+	b.updateInputs()
+}
+
+// Apply the input settings of a pin, like the firmware does
+func (b *Board) setInput(pin uint8, params config.InputParams) {
+	b.releasePWM(pin)
+	b.inputs.Configure(pin, params)
+	b.isInput[pin] = params.IsInput()
+	b.updateInputs()
+}
+
+// Apply the input settings of all pins from the configuration
+func (b *Board) configureInputs() {
+	for pin, params := range b.config.Inputs {
+		if params.IsInput() || b.isInput[pin] {
+			b.setInput(uint8(pin), params)
+		}
+	}
+}
+
+// Sample & debounce all inputs
+func (b *Board) updateInputs() {
+	raw := uint8(0)
+	for pin, isInput := range b.isInput {
+		if isInput && b.inputs.Active(uint8(pin), b.inputLevels&(1<<pin) != 0) {
+			raw |= 1 << pin
+		}
+	}
+	now := b.millisSinceStart()
+	state, _ := b.inputs.Update(raw, now)
+	b.inputState.Update(uint16(state), now)
+}
@@ -0,0 +1,92 @@
+package fakeboard
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pwmslices"
+)
+
+// SetIO0Locked sets whether IO0 is pulled down to select the alternate
+// i2c address, so it cannot be driven high.
+func (b *Board) SetIO0Locked(locked bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.io0Locked = locked
+}
+
+// PinMode returns the current mode (protocol.PinModeXyz) of the on-pcb pin with given index.
+func (b *Board) PinMode(pin uint8) uint8 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.pinMode(pin)
+}
+
+// Returns the current mode of a pin
+func (b *Board) pinMode(pin uint8) uint8 {
+	switch {
+	case b.isInput[pin]:
+		return protocol.PinModeInput
+	case b.isServo[pin]:
+		return protocol.PinModeServo
+	case b.isPWM[pin]:
+		return protocol.PinModePWM
+	default:
+		return b.digitalModes[pin]
+	}
+}
+
+// Switch a pin to the given mode, like the firmware does
+func (b *Board) setPinMode(pin, mode uint8) {
+	if !protocol.IsValidPinMode(mode) {
+		b.rejectPin(pin, protocol.PinErrorInvalidMode)
+		return
+	}
+	if mode == b.pinMode(pin) {
+		return
+	}
+	switch mode {
+	case protocol.PinModeOutput, protocol.PinModeOpenDrain, protocol.PinModeDisabled:
+		b.digitalModes[pin] = mode
+		b.setInput(pin, config.InputParams{})
+	case protocol.PinModeInput:
+		params := b.config.Inputs[pin]
+		if !params.IsInput() {
+			params.Mode = protocol.InputModePullUp
+		}
+		b.setInput(pin, params)
+	case protocol.PinModePWM, protocol.PinModeServo:
+		if pin == 0 && b.io0Locked {
+			b.rejectPin(pin, protocol.PinErrorAddressStrap)
+			return
+		}
+		b.digitalModes[pin] = protocol.PinModeOutput
+		if b.isInput[pin] {
+			b.setInput(pin, config.InputParams{})
+		}
+		if mode == protocol.PinModeServo {
+			b.setServoTarget(pin, b.config.PWMDefaults[pin])
+		} else {
+			b.setPWM(pin, pwmslices.DutyOfByte(b.config.PWMDefaults[pin]))
+		}
+	}
+}
+
+// Returns true if a pin may be switched to PWM mode.
+// Otherwise the request is reported as rejected.
+func (b *Board) canDrive(pin uint8) bool {
+	if b.isInput[pin] || (!b.isPWM[pin] && b.digitalModes[pin] == protocol.PinModeDisabled) {
+		b.rejectPin(pin, protocol.PinErrorNotOutput)
+		return false
+	}
+	if pin == 0 && b.io0Locked {
+		b.rejectPin(pin, protocol.PinErrorAddressStrap)
+		return false
+	}
+	return true
+}
+
+// Record a rejected request for a pin
+func (b *Board) rejectPin(pin, reason uint8) {
+	b.pinErrors |= 1 << pin
+	b.lastPinError = reason
+}
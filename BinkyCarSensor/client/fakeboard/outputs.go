@@ -0,0 +1,41 @@
+package fakeboard
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// SetPCFFailure sets whether writes to the PCF8574 device with given index fail.
+// Writes to devices beyond the number of devices of the board always fail.
+func (b *Board) SetPCFFailure(dev uint8, failing bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if int(dev) < len(b.pcfFailing) {
+		b.pcfFailing[dev] = failing
+	}
+}
+
+// Write a value to a PCF8574 device, recording the result like the firmware does
+func (b *Board) writePCF(dev, value uint8) {
+	b.pcfOutputs[dev] = value
+	if b.pcfFailing[dev] || dev >= b.outputCount/8 {
+		b.pcfFlags[dev] |= protocol.OutputFlagFailed
+		return
+	}
+	b.pcfApplied[dev] = value
+	b.pcfFlags[dev] = protocol.OutputFlagWritten
+}
+
+// Returns the level applied to the on-pcb output pins
+func (b *Board) appliedOutputs() uint8 {
+	applied := uint8(0)
+	for pin := uint8(0); pin < protocol.IOPinCount; pin++ {
+		if b.isPWM[pin] || b.isInput[pin] || b.digitalModes[pin] == protocol.PinModeDisabled {
+			continue
+		}
+		if pin == 0 && b.io0Locked && b.digitalModes[pin] == protocol.PinModeOutput {
+			continue
+		}
+		applied |= b.outputs & (1 << pin)
+	}
+	return applied
+}
@@ -0,0 +1,8 @@
+package fakeboard
+
+// Configure all car sensor pairs from the configuration
+func (b *Board) configurePairs() {
+	for idx, params := range b.config.Pairs {
+		b.pairs.Configure(idx, params)
+	}
+}
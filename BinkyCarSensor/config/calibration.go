@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// SensorCalibration holds the idle level of a single car sensor,
+// measured while no car is near the sensor.
+type SensorCalibration struct {
+	// Set if the sensor has been calibrated
+	Valid bool
+	// Mean raw ADS1115 value while idle
+	Baseline uint16
+	// Standard deviation of the raw ADS1115 value while idle
+	Noise uint16
+}
+
+// EncodeRecord encodes the calibration as register record of
+// protocol.CalibrationRecordSize bytes.
+func (c SensorCalibration) EncodeRecord() []byte {
+	flags := uint8(0)
+	if c.Valid {
+		flags |= protocol.CalibrationFlagValid
+	}
+	return []byte{
+		flags,
+		uint8(c.Baseline), uint8(c.Baseline >> 8),
+		uint8(c.Noise), uint8(c.Noise >> 8),
+	}
+}
+
+// DecodeCalibrationRecord decodes a register record of
+// protocol.CalibrationRecordSize bytes.
+func DecodeCalibrationRecord(record []byte) (SensorCalibration, error) {
+	if len(record) < protocol.CalibrationRecordSize {
+		return SensorCalibration{}, fmt.Errorf("Calibration record too short: %d", len(record))
+	}
+	return SensorCalibration{
+		Valid:    record[0]&protocol.CalibrationFlagValid != 0,
+		Baseline: uint16(record[1]) | (uint16(record[2]) << 8),
+		Noise:    uint16(record[3]) | (uint16(record[4]) << 8),
+	}, nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Config holds the complete configuration of a board.
+type Config struct {
+	// I2C address of the board on I2C1.
+	// If 0, the address is selected by IO1 (default or alternate address).
+	I2CAddress uint8
+	// Detection configuration of the car sensors
+	Detection Detection
+	// Power-on mode of the on-pcb IO pins (protocol.PinModeXyz)
+	PinModes [protocol.IOPinCount]uint8
+	// Power-on value of the on-pcb output pins
+	OutputDefaults uint8
+	// Power-on value of the PCF8574 output devices
+	PCFOutputDefaults [protocol.MaxPCFDevices]uint8
+	// Power-on PWM value (or servo position) of the on-pcb IO pins in PWM (or servo) mode
+	PWMDefaults [protocol.IOPinCount]uint8
+	// Servo parameters of the on-pcb IO pins
+	Servos [protocol.IOPinCount]ServoParams
+	// Pulse & safety settings of on-pcb outputs & PCF8574 outputs
+	// (see protocol.PulseOutputCount)
+	Pulses [protocol.PulseOutputCount]PulseParams
+	// Communication-loss failsafe
+	Failsafe Failsafe
+	// Input settings of the on-pcb IO pins.
+	// Pins with an input mode are inputs at power-on.
+	Inputs [protocol.IOPinCount]InputParams
+	// Car sensor pairs used to measure speed & direction
+	Pairs [protocol.MaxSensorPairs]PairParams
+	// Human readable label of the board
+	Label string
+}
+
+// Default returns the default (factory) configuration.
+func Default() Config {
+	c := Config{
+		Detection: DefaultDetection(),
+	}
+	for idx := range c.Servos {
+		c.Servos[idx] = DefaultServoParams()
+	}
+	return c
+}
+
+// Validate the configuration, returning an error if invalid.
+func (c Config) Validate() error {
+	if c.I2CAddress != 0 && (c.I2CAddress < protocol.MinI2CAddress || c.I2CAddress > protocol.MaxI2CAddress) {
+		return fmt.Errorf("I2CAddress must be 0 or 0x%02x..0x%02x, got 0x%02x", protocol.MinI2CAddress, protocol.MaxI2CAddress, c.I2CAddress)
+	}
+	if err := c.Detection.Validate(); err != nil {
+		return err
+	}
+	for idx, mode := range c.PinModes {
+		if !protocol.IsValidPinMode(mode) {
+			return fmt.Errorf("Invalid mode %d for pin %d", mode, idx)
+		}
+	}
+	for idx, s := range c.Servos {
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("Invalid servo parameters of pin %d: %w", idx, err)
+		}
+	}
+	for idx, p := range c.Inputs {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("Invalid input settings of pin %d: %w", idx, err)
+		}
+	}
+	for idx, p := range c.Pairs {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("Invalid settings of sensor pair %d: %w", idx, err)
+		}
+	}
+	if c.Failsafe.Timeout < 0 || c.Failsafe.Timeout > maxFailsafeTimeout {
+		return fmt.Errorf("Failsafe timeout must be 0..%s, got %s", maxFailsafeTimeout, c.Failsafe.Timeout)
+	}
+	if len(c.Label) > protocol.LabelMaxSize {
+		return fmt.Errorf("Label must be at most %d bytes, got %d", protocol.LabelMaxSize, len(c.Label))
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+// Package config implements the configuration of a BinkyCarSensor board
+// and its persistence in flash.
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// DetectionParams holds the parameters of the peak detector of a car sensor.
+type DetectionParams struct {
+	// Number of samples that determines the default window size (2*Lag).
+	Lag uint8
+	// Size of the sliding window of samples (0 means 2*Lag).
+	WindowSize uint8
+	// Number of standard deviations (in 1/100) from the moving mean above
+	// which a sample is classified as signal.
+	Threshold uint16
+	// Influence (in 1/100, 0..100) of signals on the detection threshold.
+	Influence uint16
+	// Minimum difference between min & max of the window for a signal to be
+	// reported as detection (0 disables this check).
+	MinMinMaxDiff uint16
+}
+
+// SensorDetectionParams holds the detection parameters of a single car sensor.
+type SensorDetectionParams struct {
+	// If set, Params are used instead of the global parameters.
+	Override bool
+	Params   DetectionParams
+}
+
+// Detection holds the detection configuration of all car sensors.
+type Detection struct {
+	// Interval between probes of all sensors.
+	ProbeInterval time.Duration
+	// Parameters used by all sensors without override
+	Global DetectionParams
+	// Per sensor overrides
+	Sensors [protocol.MaxSensorCount]SensorDetectionParams
+	// If set, all sensors are calibrated when they are found after boot
+	CalibrateAtBoot bool
+	// Idle level per sensor
+	Calibration [protocol.MaxSensorCount]SensorCalibration
+	// Detector per sensor
+	Detectors [protocol.MaxSensorCount]DetectorParams
+	// Bit N is set when sensor N stays active while a car is present
+	Presence uint16
+}
+
+const (
+	// Limits of detection parameters
+	maxWindowSize    = 64
+	maxInfluence     = 100
+	minProbeInterval = time.Millisecond * 10
+	maxProbeInterval = time.Second * 10
+)
+
+// DefaultDetectionParams returns the default detection parameters.
+func DefaultDetectionParams() DetectionParams {
+	// Algorithm configuration from example.
+	return DetectionParams{
+		Lag:       10,
+		Threshold: 750, // 7.5
+		Influence: 50,  // 0.5
+	}
+}
+
+// DefaultDetection returns the default detection configuration.
+func DefaultDetection() Detection {
+	return Detection{
+		ProbeInterval: time.Millisecond * 50,
+		Global:        DefaultDetectionParams(),
+	}
+}
+
+// EffectiveWindowSize returns the size of the sliding window.
+func (p DetectionParams) EffectiveWindowSize() int {
+	if p.WindowSize == 0 {
+		return int(p.Lag) * 2
+	}
+	return int(p.WindowSize)
+}
+
+// ThresholdValue returns the threshold as number of standard deviations.
+func (p DetectionParams) ThresholdValue() float64 {
+	return float64(p.Threshold) / 100
+}
+
+// InfluenceValue returns the influence as fraction (0.0..1.0).
+func (p DetectionParams) InfluenceValue() float64 {
+	return float64(p.Influence) / 100
+}
+
+// Validate the parameters, returning an error if invalid.
+func (p DetectionParams) Validate() error {
+	if p.Lag == 0 && p.WindowSize == 0 {
+		return fmt.Errorf("Lag or WindowSize must be set")
+	}
+	if ws := p.EffectiveWindowSize(); ws < 2 || ws > maxWindowSize {
+		return fmt.Errorf("WindowSize must be 2..%d, got %d", maxWindowSize, ws)
+	}
+	if p.Threshold == 0 {
+		return fmt.Errorf("Threshold must be > 0")
+	}
+	if p.Influence > maxInfluence {
+		return fmt.Errorf("Influence must be 0..%d, got %d", maxInfluence, p.Influence)
+	}
+	return nil
+}
+
+// ForSensor returns the detection parameters of the sensor with given index.
+func (d Detection) ForSensor(idx int) DetectionParams {
+	if idx >= 0 && idx < len(d.Sensors) && d.Sensors[idx].Override {
+		return d.Sensors[idx].Params
+	}
+	return d.Global
+}
+
+// Validate the configuration, returning an error if invalid.
+func (d Detection) Validate() error {
+	if d.ProbeInterval < minProbeInterval || d.ProbeInterval > maxProbeInterval {
+		return fmt.Errorf("ProbeInterval must be %s..%s, got %s", minProbeInterval, maxProbeInterval, d.ProbeInterval)
+	}
+	if err := d.Global.Validate(); err != nil {
+		return fmt.Errorf("Invalid global detection parameters: %w", err)
+	}
+	for idx, s := range d.Sensors {
+		if s.Override {
+			if err := s.Params.Validate(); err != nil {
+				return fmt.Errorf("Invalid detection parameters of sensor %d: %w", idx, err)
+			}
+		}
+	}
+	for idx, p := range d.Detectors {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("Invalid detector of sensor %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// Record returns the register record of the detection parameters of the
+// sensor with given index (or protocol.DetectionGlobal).
+// For sensors without override, the global parameters are returned.
+func (d Detection) Record(sensorIndex uint8) []byte {
+	if sensorIndex == protocol.DetectionGlobal {
+		return d.Global.EncodeRecord(false)
+	}
+	if int(sensorIndex) < len(d.Sensors) {
+		return d.ForSensor(int(sensorIndex)).EncodeRecord(d.Sensors[sensorIndex].Override)
+	}
+	return []byte{0xff, 0xff}
+}
+
+// SetRecord sets the detection parameters of the sensor with given index
+// (or protocol.DetectionGlobal) from the given register record.
+// The configuration is only changed if the result is valid.
+func (d *Detection) SetRecord(sensorIndex uint8, record []byte) error {
+	params, override, err := DecodeDetectionRecord(record)
+	if err != nil {
+		return err
+	}
+	update := *d
+	if sensorIndex == protocol.DetectionGlobal {
+		update.Global = params
+	} else if int(sensorIndex) < len(update.Sensors) {
+		update.Sensors[sensorIndex] = SensorDetectionParams{
+			Override: override,
+			Params:   params,
+		}
+	} else {
+		return fmt.Errorf("Invalid sensor index: %d", sensorIndex)
+	}
+	if err := update.Validate(); err != nil {
+		return err
+	}
+	*d = update
+	return nil
+}
+
+// EncodeRecord encodes the parameters as register record of
+// protocol.DetectionRecordSize bytes.
+func (p DetectionParams) EncodeRecord(override bool) []byte {
+	flags := uint8(0)
+	if override {
+		flags |= protocol.DetectionFlagOverride
+	}
+	return []byte{
+		flags,
+		p.Lag,
+		p.WindowSize,
+		uint8(p.Threshold), uint8(p.Threshold >> 8),
+		uint8(p.Influence), uint8(p.Influence >> 8),
+		uint8(p.MinMinMaxDiff), uint8(p.MinMinMaxDiff >> 8),
+	}
+}
+
+// DecodeDetectionRecord decodes a register record of
+// protocol.DetectionRecordSize bytes.
+// Returns the parameters and the override flag.
+func DecodeDetectionRecord(record []byte) (DetectionParams, bool, error) {
+	if len(record) < protocol.DetectionRecordSize {
+		return DetectionParams{}, false, fmt.Errorf("Detection record too short: %d", len(record))
+	}
+	p := DetectionParams{
+		Lag:           record[1],
+		WindowSize:    record[2],
+		Threshold:     uint16(record[3]) | (uint16(record[4]) << 8),
+		Influence:     uint16(record[5]) | (uint16(record[6]) << 8),
+		MinMinMaxDiff: uint16(record[7]) | (uint16(record[8]) << 8),
+	}
+	return p, record[0]&protocol.DetectionFlagOverride != 0, nil
+}
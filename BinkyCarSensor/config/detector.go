@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// DetectorParams holds the detector selection of a single car sensor.
+type DetectorParams struct {
+	// Detector (protocol.DetectorXyz)
+	Detector uint8
+	// Deviation of the raw value from the baseline at or above which
+	// the hysteresis detector becomes active.
+	OnThreshold uint16
+	// Deviation of the raw value from the baseline below which
+	// the hysteresis detector becomes inactive.
+	OffThreshold uint16
+	// Minimum time (in milliseconds) the hysteresis detector stays active.
+	MinOnTime uint16
+}
+
+// Validate the parameters, returning an error if invalid.
+func (p DetectorParams) Validate() error {
+	if !protocol.IsValidDetector(p.Detector) {
+		return fmt.Errorf("Invalid detector %d", p.Detector)
+	}
+	if p.Detector == protocol.DetectorHysteresis {
+		if p.OnThreshold == 0 {
+			return fmt.Errorf("OnThreshold must be > 0")
+		}
+		if p.OffThreshold > p.OnThreshold {
+			return fmt.Errorf("OffThreshold must be <= OnThreshold (%d), got %d", p.OnThreshold, p.OffThreshold)
+		}
+	}
+	return nil
+}
+
+// EncodeRecord encodes the parameters as register record of
+// protocol.DetectorRecordSize bytes.
+func (p DetectorParams) EncodeRecord() []byte {
+	return []byte{
+		p.Detector,
+		uint8(p.OnThreshold), uint8(p.OnThreshold >> 8),
+		uint8(p.OffThreshold), uint8(p.OffThreshold >> 8),
+		uint8(p.MinOnTime), uint8(p.MinOnTime >> 8),
+	}
+}
+
+// DecodeDetectorRecord decodes a register record of
+// protocol.DetectorRecordSize bytes.
+func DecodeDetectorRecord(record []byte) (DetectorParams, error) {
+	if len(record) < protocol.DetectorRecordSize {
+		return DetectorParams{}, fmt.Errorf("Detector record too short: %d", len(record))
+	}
+	return DetectorParams{
+		Detector:     record[0],
+		OnThreshold:  uint16(record[1]) | (uint16(record[2]) << 8),
+		OffThreshold: uint16(record[3]) | (uint16(record[4]) << 8),
+		MinOnTime:    uint16(record[5]) | (uint16(record[6]) << 8),
+	}, nil
+}
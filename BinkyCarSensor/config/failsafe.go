@@ -0,0 +1,27 @@
+package config
+
+import (
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Failsafe holds the communication-loss failsafe configuration.
+// When no valid transaction arrives on I2C1 within Timeout, all outputs
+// are driven to their safe state.
+type Failsafe struct {
+	// Time without valid transaction before the failsafe triggers.
+	// 0 means disabled.
+	Timeout time.Duration
+	// Safe value of the on-pcb output pins
+	Outputs uint8
+	// Safe value of the PCF8574 output devices
+	PCFOutputs [protocol.MaxPCFDevices]uint8
+	// Safe PWM value (or servo position) of the on-pcb IO pins in PWM (or servo) mode
+	PWM [protocol.IOPinCount]uint8
+}
+
+const (
+	// Limits of failsafe configuration
+	maxFailsafeTimeout = time.Millisecond * 0xffff
+)
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// InputParams holds the input settings of a single on-pcb IO pin.
+type InputParams struct {
+	// Input mode (protocol.InputModeXyz).
+	// protocol.InputModeNone means the pin is not used as input.
+	Mode uint8
+	// Time (in milliseconds) the pin must be stable before
+	// a change of its state is reported.
+	Debounce uint16
+}
+
+// IsInput returns true if the pin is used as input.
+func (p InputParams) IsInput() bool {
+	return p.Mode != protocol.InputModeNone
+}
+
+// Validate the parameters, returning an error if invalid.
+func (p InputParams) Validate() error {
+	if !protocol.IsValidInputMode(p.Mode) {
+		return fmt.Errorf("Invalid input mode %d", p.Mode)
+	}
+	return nil
+}
+
+// EncodeRecord encodes the parameters as register record of
+// protocol.InputRecordSize bytes.
+func (p InputParams) EncodeRecord() []byte {
+	return []byte{
+		p.Mode,
+		uint8(p.Debounce), uint8(p.Debounce >> 8),
+	}
+}
+
+// DecodeInputRecord decodes a register record of
+// protocol.InputRecordSize bytes.
+func DecodeInputRecord(record []byte) (InputParams, error) {
+	if len(record) < protocol.InputRecordSize {
+		return InputParams{}, fmt.Errorf("Input record too short: %d", len(record))
+	}
+	return InputParams{
+		Mode:     record[0],
+		Debounce: uint16(record[1]) | (uint16(record[2]) << 8),
+	}, nil
+}
@@ -0,0 +1,125 @@
+// Package memflash implements an in-memory flash block device that behaves
+// like NOR flash (erase sets all bits, writes can only clear bits),
+// so the config package can be tested without hardware.
+package memflash
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Flash is an in-memory flash block device.
+type Flash struct {
+	mutex          sync.Mutex
+	data           []byte
+	writeBlockSize int64
+	eraseBlockSize int64
+	eraseCount     int
+	writeCount     int
+}
+
+// New initializes a new, erased flash of given size.
+// Size must be a multiple of eraseBlockSize.
+func New(size, writeBlockSize, eraseBlockSize int64) *Flash {
+	f := &Flash{
+		data:           make([]byte, size),
+		writeBlockSize: writeBlockSize,
+		eraseBlockSize: eraseBlockSize,
+	}
+	for i := range f.data {
+		f.data[i] = 0xff
+	}
+	return f
+}
+
+// NewRP2040 initializes a new, erased flash of given size with the block sizes
+// of the RP2040 flash.
+func NewRP2040(size int64) *Flash {
+	return New(size, 256, 4096)
+}
+
+// Size returns the size of the flash in bytes.
+func (f *Flash) Size() int64 {
+	return int64(len(f.data))
+}
+
+// WriteBlockSize returns the size of a single write block.
+func (f *Flash) WriteBlockSize() int64 {
+	return f.writeBlockSize
+}
+
+// EraseBlockSize returns the size of a single erase block.
+func (f *Flash) EraseBlockSize() int64 {
+	return f.eraseBlockSize
+}
+
+// EraseCount returns the number of erased blocks so far.
+func (f *Flash) EraseCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.eraseCount
+}
+
+// WriteCount returns the number of writes so far.
+func (f *Flash) WriteCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.writeCount
+}
+
+// Bytes returns a copy of the content of the flash.
+func (f *Flash) Bytes() []byte {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]byte(nil), f.data...)
+}
+
+// Corrupt flips all bits of the byte at the given offset.
+func (f *Flash) Corrupt(off int64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.data[off] ^= 0xff
+}
+
+// ReadAt reads len(p) bytes starting at the given offset.
+func (f *Flash) ReadAt(p []byte, off int64) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if off < 0 || off+int64(len(p)) > int64(len(f.data)) {
+		return 0, fmt.Errorf("Read out of range: %d+%d", off, len(p))
+	}
+	return copy(p, f.data[off:]), nil
+}
+
+// WriteAt writes p starting at the given offset.
+// Like NOR flash, writes can only clear bits; the area must be erased first.
+func (f *Flash) WriteAt(p []byte, off int64) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if off < 0 || off+int64(len(p)) > int64(len(f.data)) {
+		return 0, fmt.Errorf("Write out of range: %d+%d", off, len(p))
+	}
+	if off%f.writeBlockSize != 0 {
+		return 0, fmt.Errorf("Write offset %d not aligned to %d", off, f.writeBlockSize)
+	}
+	for i, b := range p {
+		f.data[off+int64(i)] &= b
+	}
+	f.writeCount++
+	return len(p), nil
+}
+
+// EraseBlocks erases the given number of blocks starting at the given block.
+func (f *Flash) EraseBlocks(start, length int64) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	from, to := start*f.eraseBlockSize, (start+length)*f.eraseBlockSize
+	if start < 0 || to > int64(len(f.data)) {
+		return fmt.Errorf("Erase out of range: %d+%d", start, length)
+	}
+	for i := from; i < to; i++ {
+		f.data[i] = 0xff
+	}
+	f.eraseCount += int(length)
+	return nil
+}
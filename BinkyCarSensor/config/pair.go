@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// PairParams holds the settings of a pair of car sensors along the same
+// magnetic strip, used to measure the speed & direction of cars.
+type PairParams struct {
+	// Index of the first sensor
+	First uint8
+	// Index of the second sensor
+	Second uint8
+	// Distance (in millimeters) between the sensors (0 disables the pair)
+	Spacing uint16
+}
+
+// IsEnabled returns true if the pair is used.
+func (p PairParams) IsEnabled() bool {
+	return p.Spacing != 0
+}
+
+// Validate the parameters, returning an error if invalid.
+func (p PairParams) Validate() error {
+	if !p.IsEnabled() {
+		return nil
+	}
+	if p.First >= protocol.MaxSensorCount || p.Second >= protocol.MaxSensorCount {
+		return fmt.Errorf("Sensor indexes must be 0..%d, got %d & %d", protocol.MaxSensorCount-1, p.First, p.Second)
+	}
+	if p.First == p.Second {
+		return fmt.Errorf("Sensors of a pair must differ, got %d twice", p.First)
+	}
+	return nil
+}
+
+// EncodeRecord encodes the parameters as register record of
+// protocol.PairConfigRecordSize bytes.
+func (p PairParams) EncodeRecord() []byte {
+	return []byte{
+		p.First,
+		p.Second,
+		uint8(p.Spacing), uint8(p.Spacing >> 8),
+	}
+}
+
+// DecodePairRecord decodes a register record of
+// protocol.PairConfigRecordSize bytes.
+func DecodePairRecord(record []byte) (PairParams, error) {
+	if len(record) < protocol.PairConfigRecordSize {
+		return PairParams{}, fmt.Errorf("Pair record too short: %d", len(record))
+	}
+	return PairParams{
+		First:   record[0],
+		Second:  record[1],
+		Spacing: uint16(record[2]) | (uint16(record[3]) << 8),
+	}, nil
+}
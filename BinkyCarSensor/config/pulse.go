@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// PulseParams holds the pulse & safety settings of a single output
+// (on-pcb output pin or PCF8574 output bit).
+type PulseParams struct {
+	// Duration (in milliseconds) of a pulse.
+	// If set, the output is energized for this duration each time it is set
+	// and then released automatically.
+	// 0 means the output is a normal (non-pulse) output.
+	Duration uint16
+	// Maximum time (in milliseconds) the output may be on.
+	// The output is switched off when it has been on for longer.
+	// 0 means no limit.
+	MaxOnTime uint16
+}
+
+// EncodeRecord encodes the parameters as register record of
+// protocol.PulseRecordSize bytes.
+func (p PulseParams) EncodeRecord() []byte {
+	return []byte{
+		uint8(p.Duration), uint8(p.Duration >> 8),
+		uint8(p.MaxOnTime), uint8(p.MaxOnTime >> 8),
+	}
+}
+
+// DecodePulseRecord decodes a register record of
+// protocol.PulseRecordSize bytes.
+func DecodePulseRecord(record []byte) (PulseParams, error) {
+	if len(record) < protocol.PulseRecordSize {
+		return PulseParams{}, fmt.Errorf("Pulse record too short: %d", len(record))
+	}
+	return PulseParams{
+		Duration:  uint16(record[0]) | (uint16(record[1]) << 8),
+		MaxOnTime: uint16(record[2]) | (uint16(record[3]) << 8),
+	}, nil
+}
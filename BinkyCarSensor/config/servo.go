@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// ServoParams holds the parameters of a hobby servo connected to an on-pcb IO pin.
+type ServoParams struct {
+	// Pulse width (in microseconds) at position 0.
+	MinPulse uint16
+	// Pulse width (in microseconds) at position 255.
+	MaxPulse uint16
+	// Speed (in positions per second) at which the servo moves to its target.
+	// 0 means move immediately.
+	Speed uint16
+	// Time (in milliseconds) after reaching the target at which the signal is cut.
+	// 0 means keep the signal.
+	CutOffDelay uint16
+}
+
+const (
+	// Limits of servo parameters
+	minServoPulse = 400
+	maxServoPulse = 2600
+)
+
+// DefaultServoParams returns the default servo parameters.
+func DefaultServoParams() ServoParams {
+	return ServoParams{
+		MinPulse: 1000,
+		MaxPulse: 2000,
+		Speed:    100,
+	}
+}
+
+// Validate the parameters, returning an error if invalid.
+func (p ServoParams) Validate() error {
+	if p.MinPulse < minServoPulse || p.MinPulse > maxServoPulse {
+		return fmt.Errorf("MinPulse must be %d..%d, got %d", minServoPulse, maxServoPulse, p.MinPulse)
+	}
+	if p.MaxPulse < minServoPulse || p.MaxPulse > maxServoPulse {
+		return fmt.Errorf("MaxPulse must be %d..%d, got %d", minServoPulse, maxServoPulse, p.MaxPulse)
+	}
+	return nil
+}
+
+// EncodeRecord encodes the parameters as register record of
+// protocol.ServoRecordSize bytes.
+func (p ServoParams) EncodeRecord() []byte {
+	return []byte{
+		uint8(p.MinPulse), uint8(p.MinPulse >> 8),
+		uint8(p.MaxPulse), uint8(p.MaxPulse >> 8),
+		uint8(p.Speed), uint8(p.Speed >> 8),
+		uint8(p.CutOffDelay), uint8(p.CutOffDelay >> 8),
+	}
+}
+
+// DecodeServoRecord decodes a register record of
+// protocol.ServoRecordSize bytes.
+func DecodeServoRecord(record []byte) (ServoParams, error) {
+	if len(record) < protocol.ServoRecordSize {
+		return ServoParams{}, fmt.Errorf("Servo record too short: %d", len(record))
+	}
+	return ServoParams{
+		MinPulse:    uint16(record[0]) | (uint16(record[1]) << 8),
+		MaxPulse:    uint16(record[2]) | (uint16(record[3]) << 8),
+		Speed:       uint16(record[4]) | (uint16(record[5]) << 8),
+		CutOffDelay: uint16(record[6]) | (uint16(record[7]) << 8),
+	}, nil
+}
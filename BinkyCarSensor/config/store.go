@@ -0,0 +1,316 @@
+package config
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+var (
+	// ErrNotFound is returned when the flash contains no valid configuration.
+	ErrNotFound = errors.New("no valid configuration found")
+)
+
+// Flash is the block device that stores the configuration.
+// It is implemented by machine.Flash.
+type Flash interface {
+	ReadAt(p []byte, off int64) (n int, err error)
+	WriteAt(p []byte, off int64) (n int, err error)
+	WriteBlockSize() int64
+	EraseBlockSize() int64
+	EraseBlocks(start, length int64) error
+}
+
+// Store persists configuration in flash.
+type Store struct {
+	flash  Flash
+	offset int64 // Offset of the configuration in flash (must be a multiple of EraseBlockSize)
+}
+
+const (
+	// Configuration blob layout:
+	// magic (4), version (1), reserved (1), payload length (2), payload, crc32 (4)
+	blobMagic      = "BCSC"
+	blobHeaderSize = 8
+	blobCRCSize    = 4
+	maxPayloadSize = 1024
+
+	// Current version of the configuration blob
+	CurrentVersion = 1
+
+	// Size of the version 1 payload:
+	// detection configuration, i2c address, pin modes, output defaults, PCF8574 output defaults,
+	// PWM defaults, label length, label, servo parameters, pulse settings,
+	// failsafe timeout (2), failsafe outputs, failsafe PCF8574 outputs, failsafe PWM values,
+	// input settings, calibration mode, calibration per sensor, detector per sensor,
+	// presence mode (2), car sensor pairs
+	payloadSizeV1 = detectionSize + 1 + protocol.IOPinCount + 1 + protocol.MaxPCFDevices +
+		protocol.IOPinCount + 1 + protocol.LabelMaxSize +
+		protocol.IOPinCount*protocol.ServoRecordSize +
+		protocol.PulseOutputCount*protocol.PulseRecordSize +
+		2 + 1 + protocol.MaxPCFDevices + protocol.IOPinCount +
+		protocol.IOPinCount*protocol.InputRecordSize +
+		1 + protocol.MaxSensorCount*protocol.CalibrationRecordSize +
+		protocol.MaxSensorCount*protocol.DetectorRecordSize +
+		2 +
+		protocol.MaxSensorPairs*protocol.PairConfigRecordSize
+	// Size of the detection parameters:
+	// probe interval (2), global parameters, parameters per sensor
+	detectionSize = 2 + (1+protocol.MaxSensorCount)*protocol.DetectionRecordSize
+)
+
+// NewStore initializes a store that keeps configuration at the given offset in flash.
+func NewStore(flash Flash, offset int64) *Store {
+	return &Store{
+		flash:  flash,
+		offset: offset,
+	}
+}
+
+// Load reads the configuration from flash.
+// Configurations stored by older firmware versions are migrated to
+// the current version (add a case per version when the layout changes).
+// Returns the configuration and the version it was stored with.
+// Returns ErrNotFound if the flash contains no valid configuration.
+func (s *Store) Load() (Config, uint8, error) {
+	version, payload, err := s.readBlob()
+	if err != nil {
+		return Config{}, 0, err
+	}
+	var c Config
+	switch version {
+	case 1:
+		c, err = decodeV1(payload)
+	default:
+		return Config{}, version, fmt.Errorf("%w: unsupported version %d", ErrNotFound, version)
+	}
+	if err != nil {
+		return Config{}, version, err
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, version, fmt.Errorf("Invalid configuration in flash: %w", err)
+	}
+	return c, version, nil
+}
+
+// Save writes the given configuration to flash using the current version.
+func (s *Store) Save(c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return s.writeBlob(CurrentVersion, encodeV1(c))
+}
+
+// Erase the configuration from flash, so the next Load returns ErrNotFound.
+func (s *Store) Erase() error {
+	eraseBlockSize := s.flash.EraseBlockSize()
+	blobSize := int64(blobHeaderSize + maxPayloadSize + blobCRCSize)
+	if err := s.flash.EraseBlocks(s.offset/eraseBlockSize, roundUp(blobSize, eraseBlockSize)/eraseBlockSize); err != nil {
+		return fmt.Errorf("Failed to erase flash: %w", err)
+	}
+	return nil
+}
+
+// Read & verify the configuration blob.
+// Returns version & payload.
+func (s *Store) readBlob() (uint8, []byte, error) {
+	var header [blobHeaderSize]byte
+	if _, err := s.flash.ReadAt(header[:], s.offset); err != nil {
+		return 0, nil, fmt.Errorf("Failed to read flash: %w", err)
+	}
+	if string(header[0:4]) != blobMagic {
+		return 0, nil, fmt.Errorf("%w: missing magic", ErrNotFound)
+	}
+	version := header[4]
+	payloadSize := int(binary.LittleEndian.Uint16(header[6:8]))
+	if payloadSize > maxPayloadSize {
+		return 0, nil, fmt.Errorf("%w: payload too large (%d)", ErrNotFound, payloadSize)
+	}
+	blob := make([]byte, blobHeaderSize+payloadSize+blobCRCSize)
+	if _, err := s.flash.ReadAt(blob, s.offset); err != nil {
+		return 0, nil, fmt.Errorf("Failed to read flash: %w", err)
+	}
+	crcOffset := blobHeaderSize + payloadSize
+	expectedCRC := binary.LittleEndian.Uint32(blob[crcOffset:])
+	if actualCRC := crc32.ChecksumIEEE(blob[:crcOffset]); actualCRC != expectedCRC {
+		return 0, nil, fmt.Errorf("%w: checksum mismatch", ErrNotFound)
+	}
+	return version, blob[blobHeaderSize:crcOffset], nil
+}
+
+// Write a configuration blob with given version & payload.
+func (s *Store) writeBlob(version uint8, payload []byte) error {
+	blobSize := int64(blobHeaderSize + len(payload) + blobCRCSize)
+	writeBlockSize := s.flash.WriteBlockSize()
+	blob := make([]byte, roundUp(blobSize, writeBlockSize))
+	for i := range blob {
+		// Erased flash state
+		blob[i] = 0xff
+	}
+	copy(blob[0:4], blobMagic)
+	blob[4] = version
+	blob[5] = 0
+	binary.LittleEndian.PutUint16(blob[6:8], uint16(len(payload)))
+	copy(blob[blobHeaderSize:], payload)
+	crcOffset := blobHeaderSize + len(payload)
+	binary.LittleEndian.PutUint32(blob[crcOffset:], crc32.ChecksumIEEE(blob[:crcOffset]))
+
+	eraseBlockSize := s.flash.EraseBlockSize()
+	if err := s.flash.EraseBlocks(s.offset/eraseBlockSize, roundUp(blobSize, eraseBlockSize)/eraseBlockSize); err != nil {
+		return fmt.Errorf("Failed to erase flash: %w", err)
+	}
+	if _, err := s.flash.WriteAt(blob, s.offset); err != nil {
+		return fmt.Errorf("Failed to write flash: %w", err)
+	}
+	return nil
+}
+
+// Encode a version 1 payload
+func encodeV1(c Config) []byte {
+	payload := make([]byte, 0, payloadSizeV1)
+	payload = append(payload, encodeDetection(c.Detection)...)
+	payload = append(payload, c.I2CAddress)
+	payload = append(payload, c.PinModes[:]...)
+	payload = append(payload, c.OutputDefaults)
+	payload = append(payload, c.PCFOutputDefaults[:]...)
+	payload = append(payload, c.PWMDefaults[:]...)
+	var label [protocol.LabelMaxSize]byte
+	copy(label[:], c.Label)
+	payload = append(payload, uint8(len(c.Label)))
+	payload = append(payload, label[:]...)
+	for _, s := range c.Servos {
+		payload = append(payload, s.EncodeRecord()...)
+	}
+	for _, p := range c.Pulses {
+		payload = append(payload, p.EncodeRecord()...)
+	}
+	payload = binary.LittleEndian.AppendUint16(payload, uint16(c.Failsafe.Timeout/time.Millisecond))
+	payload = append(payload, c.Failsafe.Outputs)
+	payload = append(payload, c.Failsafe.PCFOutputs[:]...)
+	payload = append(payload, c.Failsafe.PWM[:]...)
+	for _, p := range c.Inputs {
+		payload = append(payload, p.EncodeRecord()...)
+	}
+	mode := uint8(0)
+	if c.Detection.CalibrateAtBoot {
+		mode |= protocol.CalibrationModeAtBoot
+	}
+	payload = append(payload, mode)
+	for _, sc := range c.Detection.Calibration {
+		payload = append(payload, sc.EncodeRecord()...)
+	}
+	for _, dp := range c.Detection.Detectors {
+		payload = append(payload, dp.EncodeRecord()...)
+	}
+	payload = binary.LittleEndian.AppendUint16(payload, c.Detection.Presence)
+	for _, pp := range c.Pairs {
+		payload = append(payload, pp.EncodeRecord()...)
+	}
+	return payload
+}
+
+// Decode a version 1 payload
+func decodeV1(payload []byte) (Config, error) {
+	if len(payload) < payloadSizeV1 {
+		return Config{}, fmt.Errorf("Payload too short: %d", len(payload))
+	}
+	c := Default()
+	var err error
+	if c.Detection, err = decodeDetection(payload); err != nil {
+		return Config{}, err
+	}
+	payload = payload[detectionSize:]
+	c.I2CAddress = payload[0]
+	payload = payload[1:]
+	payload = payload[copy(c.PinModes[:], payload):]
+	c.OutputDefaults = payload[0]
+	payload = payload[1:]
+	payload = payload[copy(c.PCFOutputDefaults[:], payload):]
+	payload = payload[copy(c.PWMDefaults[:], payload):]
+	labelLen := min(int(payload[0]), protocol.LabelMaxSize)
+	c.Label = string(payload[1 : 1+labelLen])
+	payload = payload[1+protocol.LabelMaxSize:]
+	for idx := range c.Servos {
+		if c.Servos[idx], err = DecodeServoRecord(payload); err != nil {
+			return Config{}, err
+		}
+		payload = payload[protocol.ServoRecordSize:]
+	}
+	for idx := range c.Pulses {
+		if c.Pulses[idx], err = DecodePulseRecord(payload); err != nil {
+			return Config{}, err
+		}
+		payload = payload[protocol.PulseRecordSize:]
+	}
+	c.Failsafe.Timeout = time.Duration(binary.LittleEndian.Uint16(payload[0:2])) * time.Millisecond
+	c.Failsafe.Outputs = payload[2]
+	payload = payload[3:]
+	payload = payload[copy(c.Failsafe.PCFOutputs[:], payload):]
+	payload = payload[copy(c.Failsafe.PWM[:], payload):]
+	for idx := range c.Inputs {
+		if c.Inputs[idx], err = DecodeInputRecord(payload); err != nil {
+			return Config{}, err
+		}
+		payload = payload[protocol.InputRecordSize:]
+	}
+	c.Detection.CalibrateAtBoot = payload[0]&protocol.CalibrationModeAtBoot != 0
+	payload = payload[1:]
+	for idx := range c.Detection.Calibration {
+		if c.Detection.Calibration[idx], err = DecodeCalibrationRecord(payload); err != nil {
+			return Config{}, err
+		}
+		payload = payload[protocol.CalibrationRecordSize:]
+	}
+	for idx := range c.Detection.Detectors {
+		if c.Detection.Detectors[idx], err = DecodeDetectorRecord(payload); err != nil {
+			return Config{}, err
+		}
+		payload = payload[protocol.DetectorRecordSize:]
+	}
+	c.Detection.Presence = binary.LittleEndian.Uint16(payload)
+	payload = payload[2:]
+	for idx := range c.Pairs {
+		if c.Pairs[idx], err = DecodePairRecord(payload); err != nil {
+			return Config{}, err
+		}
+		payload = payload[protocol.PairConfigRecordSize:]
+	}
+	return c, nil
+}
+
+// Encode the detection configuration
+func encodeDetection(d Detection) []byte {
+	payload := make([]byte, 0, detectionSize)
+	payload = binary.LittleEndian.AppendUint16(payload, uint16(d.ProbeInterval/time.Millisecond))
+	payload = append(payload, d.Global.EncodeRecord(false)...)
+	for _, sp := range d.Sensors {
+		payload = append(payload, sp.Params.EncodeRecord(sp.Override)...)
+	}
+	return payload
+}
+
+// Decode the detection configuration
+func decodeDetection(payload []byte) (Detection, error) {
+	if len(payload) < detectionSize {
+		return Detection{}, fmt.Errorf("Detection payload too short: %d", len(payload))
+	}
+	var d Detection
+	d.ProbeInterval = time.Duration(binary.LittleEndian.Uint16(payload[0:2])) * time.Millisecond
+	payload = payload[2:]
+	d.Global, _, _ = DecodeDetectionRecord(payload)
+	payload = payload[protocol.DetectionRecordSize:]
+	for idx := range d.Sensors {
+		d.Sensors[idx].Params, d.Sensors[idx].Override, _ = DecodeDetectionRecord(payload)
+		payload = payload[protocol.DetectionRecordSize:]
+	}
+	return d, nil
+}
+
+// Round x up to a multiple of n
+func roundUp(x, n int64) int64 {
+	return ((x + n - 1) / n) * n
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config/memflash"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+const (
+	// Offset of the configuration in the test flash
+	testOffset = 4096
+)
+
+// Create a store on an erased RP2040-like flash
+func newTestStore() (*Store, *memflash.Flash) {
+	flash := memflash.NewRP2040(4 * 4096)
+	return NewStore(flash, testOffset), flash
+}
+
+// Returns a configuration with all parts set to non-default values
+func testConfig() Config {
+	c := Default()
+	c.I2CAddress = 0x42
+	c.Detection.ProbeInterval = time.Millisecond * 120
+	c.Detection.Global = DetectionParams{Lag: 12, WindowSize: 30, Threshold: 500, Influence: 20, MinMinMaxDiff: 40}
+	c.Detection.Sensors[3] = SensorDetectionParams{Override: true, Params: DetectionParams{Lag: 5, Threshold: 300, Influence: 10}}
+	c.Detection.CalibrateAtBoot = true
+	c.Detection.Calibration[2] = SensorCalibration{Valid: true, Baseline: 13333, Noise: 12}
+	c.Detection.Detectors[2] = DetectorParams{Detector: protocol.DetectorHysteresis, OnThreshold: 800, OffThreshold: 400, MinOnTime: 100}
+	c.Detection.Presence = 0x0004
+	c.PinModes[1] = protocol.PinModePWM
+	c.PinModes[2] = protocol.PinModeServo
+	c.PinModes[5] = protocol.PinModeInput
+	c.OutputDefaults = 0x81
+	c.PCFOutputDefaults[0] = 0xf0
+	c.PWMDefaults[1] = 128
+	c.Servos[2] = ServoParams{MinPulse: 1100, MaxPulse: 1900, Speed: 50, CutOffDelay: 500}
+	c.Pulses[0] = PulseParams{Duration: 250, MaxOnTime: 1000}
+	c.Failsafe.Timeout = time.Second * 2
+	c.Failsafe.Outputs = 0x01
+	c.Failsafe.PCFOutputs[1] = 0x0f
+	c.Failsafe.PWM[1] = 10
+	c.Inputs[5] = InputParams{Mode: protocol.InputModePullUp, Debounce: 20}
+	c.Pairs[0] = PairParams{First: 0, Second: 1, Spacing: 100}
+	c.Label = "Station west"
+	return c
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	store, flash := newTestStore()
+	expected := testConfig()
+	if err := store.Save(expected); err != nil {
+		t.Fatalf("Save failed: %s", err)
+	}
+	c, version, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %s", err)
+	}
+	if version != CurrentVersion {
+		t.Errorf("expected version %d, got %d", CurrentVersion, version)
+	}
+	if c != expected {
+		t.Errorf("expected %+v, got %+v", expected, c)
+	}
+	// Flash outside the configuration is untouched
+	for i, b := range flash.Bytes()[:testOffset] {
+		if b != 0xff {
+			t.Fatalf("expected byte %d before the configuration to be erased, got 0x%02x", i, b)
+		}
+	}
+}
+
+func TestStoreSaveInvalid(t *testing.T) {
+	store, flash := newTestStore()
+	c := Default()
+	c.I2CAddress = 0x01
+	if err := store.Save(c); err == nil {
+		t.Error("expected invalid configuration to be rejected")
+	}
+	if flash.WriteCount() != 0 {
+		t.Errorf("expected no writes, got %d", flash.WriteCount())
+	}
+}
+
+func TestStorePayloadSize(t *testing.T) {
+	if size := len(encodeV1(testConfig())); size != payloadSizeV1 {
+		t.Errorf("expected payload of %d bytes, got %d", payloadSizeV1, size)
+	}
+	if size := blobHeaderSize + payloadSizeV1 + blobCRCSize; size > blobHeaderSize+maxPayloadSize+blobCRCSize {
+		t.Errorf("blob of %d bytes exceeds the maximum payload size", size)
+	}
+}
+
+func TestStoreLoadErased(t *testing.T) {
+	store, _ := newTestStore()
+	if _, _, err := store.Load(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStoreRejectsCorruption(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int64
+	}{
+		{"magic", 0},
+		{"version", 4},
+		{"payload length", 6},
+		{"payload", blobHeaderSize + 10},
+		{"crc", blobHeaderSize + payloadSizeV1},
+	}
+	for _, test := range tests {
+		store, flash := newTestStore()
+		if err := store.Save(testConfig()); err != nil {
+			t.Fatalf("%s: Save failed: %s", test.name, err)
+		}
+		flash.Corrupt(testOffset + test.offset)
+		if _, _, err := store.Load(); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", test.name, err)
+		}
+	}
+}
+
+func TestStoreRejectsUnsupportedVersion(t *testing.T) {
+	store, _ := newTestStore()
+	if err := store.writeBlob(CurrentVersion+1, encodeV1(testConfig())); err != nil {
+		t.Fatalf("writeBlob failed: %s", err)
+	}
+	if _, version, err := store.Load(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	} else if version != CurrentVersion+1 {
+		t.Errorf("expected version %d, got %d", CurrentVersion+1, version)
+	}
+}
+
+func TestStoreRejectsShortPayload(t *testing.T) {
+	store, _ := newTestStore()
+	if err := store.writeBlob(CurrentVersion, encodeV1(testConfig())[:payloadSizeV1-1]); err != nil {
+		t.Fatalf("writeBlob failed: %s", err)
+	}
+	if _, _, err := store.Load(); err == nil {
+		t.Error("expected short payload to be rejected")
+	}
+}
+
+func TestStoreErase(t *testing.T) {
+	store, flash := newTestStore()
+	if err := store.Save(testConfig()); err != nil {
+		t.Fatalf("Save failed: %s", err)
+	}
+	if err := store.Erase(); err != nil {
+		t.Fatalf("Erase failed: %s", err)
+	}
+	if _, _, err := store.Load(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after factory reset, got %v", err)
+	}
+	for i, b := range flash.Bytes() {
+		if b != 0xff {
+			t.Fatalf("expected flash to be erased, got 0x%02x at %d", b, i)
+		}
+	}
+
+	// The factory configuration can be saved again
+	if err := store.Save(Default()); err != nil {
+		t.Fatalf("Save failed: %s", err)
+	}
+	if c, _, err := store.Load(); err != nil {
+		t.Errorf("Load failed: %s", err)
+	} else if c != Default() {
+		t.Errorf("expected default configuration, got %+v", c)
+	}
+}
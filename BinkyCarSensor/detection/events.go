@@ -0,0 +1,120 @@
+package detection
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+const (
+	// Maximum number of queued events
+	EventQueueSize = 32
+)
+
+// Event is a single car sensor transition.
+type Event struct {
+	// Index of the sensor
+	Sensor uint8
+	// Set if the sensor became active, unset if it became inactive.
+	Active bool
+	// Set if the detection has negative polarity (sensor output below its baseline).
+	Negative bool
+	// Time of the transition in milliseconds since boot.
+	Timestamp uint32
+}
+
+// Encode the event into a register reply of protocol.EventRecordSize bytes.
+func (e Event) Encode(overflow bool) []byte {
+	return e.encode(protocol.EventFlagValid, overflow)
+}
+
+// Encode the event with given initial flags
+func (e Event) encode(flags uint8, overflow bool) []byte {
+	if e.Active {
+		flags |= protocol.EventFlagActive
+	}
+	if e.Negative {
+		flags |= protocol.EventFlagNegative
+	}
+	if overflow {
+		flags |= protocol.EventFlagOverflow
+	}
+	return []byte{
+		flags,
+		e.Sensor,
+		uint8(e.Timestamp),
+		uint8(e.Timestamp >> 8),
+		uint8(e.Timestamp >> 16),
+		uint8(e.Timestamp >> 24),
+	}
+}
+
+// EventQueue is a bounded FIFO of events.
+// When the queue is full, new events are dropped and the overflow flag
+// is set until the queue has been drained.
+// It is not safe for concurrent use.
+type EventQueue struct {
+	events   [EventQueueSize]Event
+	head     int
+	count    int
+	overflow bool
+}
+
+// Push an event to the end of the queue.
+// Returns false if the queue is full.
+func (q *EventQueue) Push(e Event) bool {
+	if q.count == len(q.events) {
+		q.overflow = true
+		return false
+	}
+	q.events[(q.head+q.count)%len(q.events)] = e
+	q.count++
+	return true
+}
+
+// Pop the oldest event from the queue.
+// Returns the event, the overflow flag and true if an event was popped.
+// The overflow flag is cleared once the queue is empty.
+func (q *EventQueue) Pop() (Event, bool, bool) {
+	overflow := q.overflow
+	if q.count == 0 {
+		q.overflow = false
+		return Event{}, overflow, false
+	}
+	e := q.events[q.head]
+	q.head = (q.head + 1) % len(q.events)
+	q.count--
+	if q.count == 0 {
+		q.overflow = false
+	}
+	return e, overflow, true
+}
+
+// PopRecord pops the oldest event from the queue and returns it encoded
+// as register reply of protocol.EventRecordSize bytes.
+// If the queue is empty, the valid flag of the record is unset.
+func (q *EventQueue) PopRecord() []byte {
+	e, overflow, ok := q.Pop()
+	if !ok {
+		return e.encode(0, overflow)
+	}
+	return e.Encode(overflow)
+}
+
+// StatusRecord returns the register reply of the event status register:
+// number of queued events, flags.
+func (q *EventQueue) StatusRecord() []byte {
+	flags := uint8(0)
+	if q.overflow {
+		flags |= protocol.EventFlagOverflow
+	}
+	return []byte{uint8(q.count), flags}
+}
+
+// Len returns the number of queued events.
+func (q *EventQueue) Len() int {
+	return q.count
+}
+
+// Overflow returns true if events have been dropped since the queue was last drained.
+func (q *EventQueue) Overflow() bool {
+	return q.overflow
+}
@@ -0,0 +1,119 @@
+// Package detection implements the bookkeeping of car sensor detections
+// as exposed over I2C.
+// It is used by the firmware and by the fakeboard package, so both behave
+// the same.
+package detection
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// State tracks the state of all car sensors of a board.
+// It is not safe for concurrent use.
+type State struct {
+	current      uint16 // Current sensor status
+	latch        uint16 // Sensor status since last read of the latch
+	risingEdges  uint16 // Sensors that became active since last acknowledge
+	fallingEdges uint16 // Sensors that became inactive since last acknowledge
+	polarity     uint16 // Sensors whose current (or last) detection has negative polarity
+	passCounts   [protocol.MaxSensorCount]uint16
+	events       EventQueue
+}
+
+// Update the state with the current status of all sensors, measured
+// at the given time (in milliseconds since boot).
+// Bit N is set when sensor N is active.
+// Every transition is queued as event (ordered by sensor index).
+// Returns true if the status changed.
+func (s *State) Update(x uint16, timestamp uint32) bool {
+	if x == s.current {
+		return false
+	}
+	rising := x &^ s.current
+	falling := s.current &^ x
+	s.current = x
+	s.latch |= x
+	s.risingEdges |= rising
+	s.fallingEdges |= falling
+	for idx := range s.passCounts {
+		mask := uint16(1) << idx
+		if rising&mask != 0 {
+			s.passCounts[idx]++
+		}
+		if (rising|falling)&mask != 0 {
+			s.events.Push(Event{
+				Sensor:    uint8(idx),
+				Active:    rising&mask != 0,
+				Negative:  s.polarity&mask != 0,
+				Timestamp: timestamp,
+			})
+		}
+	}
+	return true
+}
+
+// SetPolarity sets the polarity of the detections of all sensors,
+// used for the events queued by the next Update.
+// Bit N is set when the detection of sensor N has negative polarity.
+func (s *State) SetPolarity(x uint16) {
+	s.polarity = x
+}
+
+// Polarity returns the polarity of the current (or last) detection of all sensors.
+func (s *State) Polarity() uint16 {
+	return s.polarity
+}
+
+// Events returns the queue of sensor transitions.
+func (s *State) Events() *EventQueue {
+	return &s.events
+}
+
+// Current returns the current status of all sensors.
+func (s *State) Current() uint16 {
+	return s.current
+}
+
+// ReadLatch returns all sensors that have been active since the previous
+// call and resets the latch to the current status.
+func (s *State) ReadLatch() uint16 {
+	result := s.latch
+	s.latch = s.current
+	return result
+}
+
+// RisingEdges returns all sensors that became active since their
+// edges were last acknowledged.
+func (s *State) RisingEdges() uint16 {
+	return s.risingEdges
+}
+
+// FallingEdges returns all sensors that became inactive since their
+// edges were last acknowledged.
+func (s *State) FallingEdges() uint16 {
+	return s.fallingEdges
+}
+
+// AckEdges clears the rising & falling edges of all sensors in the given mask.
+func (s *State) AckEdges(mask uint16) {
+	s.risingEdges &^= mask
+	s.fallingEdges &^= mask
+}
+
+// PassCount returns the number of times the sensor with given index became
+// active. The counter wraps around at 0xffff.
+func (s *State) PassCount(idx int) uint16 {
+	if idx < 0 || idx >= len(s.passCounts) {
+		return 0
+	}
+	return s.passCounts[idx]
+}
+
+// ResetPassCounts resets the pass counters of all sensors in the given mask.
+func (s *State) ResetPassCounts(mask uint16) {
+	for idx := range s.passCounts {
+		if mask&(1<<idx) != 0 {
+			s.passCounts[idx] = 0
+		}
+	}
+}
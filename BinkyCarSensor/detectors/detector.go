@@ -0,0 +1,58 @@
+// Package detectors implements the algorithms that decide whether a car
+// is detected from the probe values of a single car sensor.
+package detectors
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Signal is the classification of a probe value.
+type Signal uint8
+
+const (
+	// No car detected
+	SignalNone Signal = iota
+	// Car detected, probe value above the baseline
+	SignalPositive
+	// Car detected, probe value below the baseline
+	SignalNegative
+)
+
+// Detector classifies the probe values of a single car sensor.
+type Detector interface {
+	// Next processes the next raw probe value, taken at the given time
+	// (in milliseconds), and returns the resulting signal.
+	Next(raw uint16, now uint32) Signal
+}
+
+// New creates the detector selected in the given parameters.
+func New(detector config.DetectorParams, params config.DetectionParams, calib config.SensorCalibration) Detector {
+	switch detector.Detector {
+	case protocol.DetectorHysteresis:
+		return newHysteresis(detector, calib)
+	default:
+		return newZScore(params, calib)
+	}
+}
+
+// Returns the deviation of the given raw value from the calibrated baseline
+func deviation(raw uint16, calib config.SensorCalibration) int32 {
+	return int32(raw) - int32(calib.Baseline)
+}
+
+// Returns the signal of a detection with given deviation from the baseline
+func signalOf(dev int32) Signal {
+	if dev < 0 {
+		return SignalNegative
+	}
+	return SignalPositive
+}
+
+// Returns the absolute value of the given deviation
+func abs(dev int32) int32 {
+	if dev < 0 {
+		return -dev
+	}
+	return dev
+}
@@ -0,0 +1,44 @@
+package detectors
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+)
+
+// hysteresis is a Schmitt-trigger on the deviation from the calibrated baseline.
+// It becomes active when the deviation reaches the on threshold and inactive
+// when it drops below the off threshold, but not before the minimum on time.
+type hysteresis struct {
+	params  config.DetectorParams
+	calib   config.SensorCalibration
+	signal  Signal
+	onSince uint32 // Time the detector became active
+}
+
+// Create a hysteresis detector
+func newHysteresis(params config.DetectorParams, calib config.SensorCalibration) *hysteresis {
+	if !calib.Valid {
+		println("Hysteresis detector requires a calibrated sensor")
+	}
+	return &hysteresis{
+		params: params,
+		calib:  calib,
+	}
+}
+
+// Next compares the given probe value with the thresholds
+func (d *hysteresis) Next(raw uint16, now uint32) Signal {
+	if !d.calib.Valid {
+		// Without baseline there is nothing to compare with
+		return SignalNone
+	}
+	dev := deviation(raw, d.calib)
+	if d.signal == SignalNone {
+		if abs(dev) >= int32(d.params.OnThreshold) {
+			d.signal = signalOf(dev)
+			d.onSince = now
+		}
+	} else if abs(dev) < int32(d.params.OffThreshold) && now-d.onSince >= uint32(d.params.MinOnTime) {
+		d.signal = SignalNone
+	}
+	return d.signal
+}
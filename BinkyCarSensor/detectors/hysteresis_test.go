@@ -0,0 +1,58 @@
+package detectors
+
+import (
+	"testing"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// A single probe value with the expected resulting signal
+type probeStep struct {
+	name     string
+	raw      uint16
+	now      uint32
+	expected Signal
+}
+
+// Pass all steps to the given detector
+func runProbeSteps(t *testing.T, d Detector, steps []probeStep) {
+	t.Helper()
+	for _, s := range steps {
+		if signal := d.Next(s.raw, s.now); signal != s.expected {
+			t.Errorf("%s: expected signal %d, got %d", s.name, s.expected, signal)
+		}
+	}
+}
+
+func TestHysteresis(t *testing.T) {
+	params := config.DetectorParams{Detector: protocol.DetectorHysteresis, OnThreshold: 800, OffThreshold: 400, MinOnTime: 100}
+	calib := config.SensorCalibration{Valid: true, Baseline: 13000, Noise: 10}
+	d := New(params, config.DefaultDetectionParams(), calib)
+	if _, ok := d.(*hysteresis); !ok {
+		t.Fatalf("expected hysteresis detector, got %T", d)
+	}
+	runProbeSteps(t, d, []probeStep{
+		{"below on threshold", 13500, 1000, SignalNone},
+		{"on threshold", 13800, 1010, SignalPositive},
+		{"below off threshold within min on time", 13100, 1050, SignalPositive},
+		{"above off threshold", 13500, 1110, SignalPositive},
+		{"below off threshold", 13399, 1120, SignalNone},
+		{"negative below on threshold", 12300, 1130, SignalNone},
+		{"negative on threshold", 12200, 1140, SignalNegative},
+		{"baseline just before min on time", 13000, 1239, SignalNegative},
+		{"baseline after min on time", 13000, 1240, SignalNone},
+		{"on threshold before timer wrap", 14000, 0xffffffce, SignalPositive},
+		{"baseline within min on time after wrap", 13000, 0x00000031, SignalPositive},
+		{"baseline after min on time after wrap", 13000, 0x00000032, SignalNone},
+	})
+}
+
+func TestHysteresisUncalibrated(t *testing.T) {
+	params := config.DetectorParams{Detector: protocol.DetectorHysteresis, OnThreshold: 800, OffThreshold: 400}
+	d := New(params, config.DefaultDetectionParams(), config.SensorCalibration{})
+	runProbeSteps(t, d, []probeStep{
+		{"far above", 0xffff, 0, SignalNone},
+		{"far below", 0, 10, SignalNone},
+	})
+}
@@ -0,0 +1,38 @@
+package detectors
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+)
+
+// presence keeps a detection active for as long as the probe values stay away
+// from the calibrated baseline, so a car that stops over the sensor is still
+// reported after the wrapped detector has adapted to it.
+type presence struct {
+	detector Detector
+	calib    config.SensorCalibration
+	signal   Signal
+}
+
+// WithPresence wraps the given detector, so it stays active while a car is present.
+// Uncalibrated sensors have no baseline, so their detector is returned as is.
+func WithPresence(detector Detector, calib config.SensorCalibration) Detector {
+	if !calib.Valid {
+		println("Presence mode requires a calibrated sensor")
+		return detector
+	}
+	return &presence{
+		detector: detector,
+		calib:    calib,
+	}
+}
+
+// Next passes the probe value to the wrapped detector & holds its detection
+func (d *presence) Next(raw uint16, now uint32) Signal {
+	if signal := d.detector.Next(raw, now); signal != SignalNone {
+		d.signal = signal
+	} else if abs(deviation(raw, d.calib)) < calibratedNoiseFactor*int32(d.calib.Noise) {
+		// Car is gone
+		d.signal = SignalNone
+	}
+	return d.signal
+}
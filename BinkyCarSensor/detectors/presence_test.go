@@ -0,0 +1,69 @@
+package detectors
+
+import (
+	"testing"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Detector that returns a scripted signal
+type scriptedDetector struct {
+	signal Signal
+}
+
+func (d *scriptedDetector) Next(raw uint16, now uint32) Signal {
+	return d.signal
+}
+
+func TestPresenceHold(t *testing.T) {
+	inner := &scriptedDetector{}
+	// Car is gone within 4 * noise (40) of the baseline
+	calib := config.SensorCalibration{Valid: true, Baseline: 13000, Noise: 10}
+	d := WithPresence(inner, calib)
+
+	steps := []struct {
+		name     string
+		inner    Signal
+		raw      uint16
+		expected Signal
+	}{
+		{"idle", SignalNone, 13000, SignalNone},
+		{"idle with deviation", SignalNone, 13500, SignalNone},
+		{"detected", SignalPositive, 13500, SignalPositive},
+		{"held while away from baseline", SignalNone, 13300, SignalPositive},
+		{"held at noise band", SignalNone, 13040, SignalPositive},
+		{"released within noise band", SignalNone, 13039, SignalNone},
+		{"negative detection", SignalNegative, 12500, SignalNegative},
+		{"negative held at noise band", SignalNone, 12960, SignalNegative},
+		{"negative released within noise band", SignalNone, 12961, SignalNone},
+		{"detection at baseline", SignalPositive, 13000, SignalPositive},
+		{"polarity follows wrapped detector", SignalNegative, 12000, SignalNegative},
+	}
+	for idx, s := range steps {
+		inner.signal = s.inner
+		if signal := d.Next(s.raw, uint32(idx*10)); signal != s.expected {
+			t.Errorf("%s: expected signal %d, got %d", s.name, s.expected, signal)
+		}
+	}
+}
+
+func TestPresenceWithHysteresis(t *testing.T) {
+	params := config.DetectorParams{Detector: protocol.DetectorHysteresis, OnThreshold: 800, OffThreshold: 400}
+	calib := config.SensorCalibration{Valid: true, Baseline: 13000, Noise: 10}
+	d := WithPresence(New(params, config.DefaultDetectionParams(), calib), calib)
+	runProbeSteps(t, d, []probeStep{
+		{"car arrives", 14000, 0, SignalPositive},
+		{"car stops above off threshold", 13500, 100, SignalPositive},
+		{"car stops below off threshold", 13200, 200, SignalPositive},
+		{"car stays", 13100, 60000, SignalPositive},
+		{"car leaves", 13010, 60100, SignalNone},
+	})
+}
+
+func TestPresenceUncalibrated(t *testing.T) {
+	inner := &scriptedDetector{}
+	if d := WithPresence(inner, config.SensorCalibration{}); d != inner {
+		t.Errorf("expected uncalibrated sensor to use the wrapped detector, got %T", d)
+	}
+}
@@ -0,0 +1,102 @@
+package detectors
+
+import (
+	"github.com/MicahParks/peakdetect"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+)
+
+const (
+	// Minimum deviation from the calibrated baseline (in multiples of the noise)
+	// for a signal to be reported as detection
+	calibratedNoiseFactor = 4
+)
+
+// zScore detects peaks in a sliding window of probe values.
+// Calibrated sensors work relative to their baseline.
+type zScore struct {
+	params config.DetectionParams
+	calib  config.SensorCalibration
+
+	window        []float64
+	curWindowSize int
+	signal        Signal
+	initialized   bool
+	detector      peakdetect.PeakDetector
+}
+
+// Create a z-score peak detector
+func newZScore(params config.DetectionParams, calib config.SensorCalibration) *zScore {
+	return &zScore{
+		params:   params,
+		calib:    calib,
+		window:   make([]float64, params.EffectiveWindowSize()),
+		detector: peakdetect.NewPeakDetector(),
+	}
+}
+
+// Next adds the given most recent probe value to the sliding window
+func (d *zScore) Next(raw uint16, now uint32) Signal {
+	value := float64(raw) / 10.0
+	if d.calib.Valid {
+		// Work relative to the baseline
+		value = float64(deviation(raw, d.calib)) / 10.0
+	}
+	windowSize := len(d.window)
+	if d.curWindowSize < windowSize {
+		// Add to window
+		d.window[d.curWindowSize] = value
+		d.curWindowSize++
+	} else {
+		// Move first (oldest) entry out of window
+		copy(d.window, d.window[1:])
+		d.window[windowSize-1] = value
+
+		// Initialize detector if needed
+		if !d.initialized {
+			if err := d.detector.Initialize(d.params.InfluenceValue(), d.params.ThresholdValue(), d.window); err != nil {
+				println("Detected failed to initialise: ", err)
+			} else {
+				d.initialized = true
+			}
+		} else {
+			switch signal := d.detector.Next(value); {
+			case signal == peakdetect.SignalNeutral:
+				d.signal = SignalNone
+			case d.windowMinMaxDiff() < float64(d.params.MinMinMaxDiff) || !d.aboveNoise(raw):
+				// Ignore signals in a window without significant changes
+				d.signal = SignalNone
+			case d.calib.Valid:
+				d.signal = signalOf(deviation(raw, d.calib))
+			case signal == peakdetect.SignalNegative:
+				// Without baseline, the sign is relative to the moving mean
+				d.signal = SignalNegative
+			default:
+				d.signal = SignalPositive
+			}
+		}
+	}
+	return d.signal
+}
+
+// Returns true if the given raw value deviates significantly from the
+// calibrated baseline (always true for uncalibrated sensors)
+func (d *zScore) aboveNoise(raw uint16) bool {
+	if !d.calib.Valid {
+		return true
+	}
+	return abs(deviation(raw, d.calib)) >= calibratedNoiseFactor*int32(d.calib.Noise)
+}
+
+// Returns the difference between the min & max value of the window
+func (d *zScore) windowMinMaxDiff() float64 {
+	min, max := d.window[0], d.window[0]
+	for _, v := range d.window[1:] {
+		if v < min {
+			min = v
+		} else if v > max {
+			max = v
+		}
+	}
+	return max - min
+}
@@ -0,0 +1,346 @@
+// Package ads1115sim implements an in-memory, register-accurate model of
+// an ADS1115 analog-digital converter.
+//
+// Attach a Simulator to a mockbus.Bus to exercise the ads1115 driver
+// (and code built on it) without hardware:
+//
+//	bus := mockbus.New()
+//	sim := ads1115sim.New()
+//	sim.SetWaveform(0, ads1115sim.Constant(2.5))
+//	bus.Attach(ads1115.I2CAddressGround, sim)
+//	dev := ads1115.New(bus, ads1115.I2CAddressGround)
+package ads1115sim
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus/mockbus"
+)
+
+const (
+	// Number of input channels
+	ChannelCount = 4
+
+	// ADS1115 registers
+	RegConversion  = 0x00
+	RegConfig      = 0x01
+	RegLoThreshold = 0x02
+	RegHiThreshold = 0x03
+
+	// Register defaults after power-up / reset
+	DefaultConfig      uint16 = 0x8583
+	DefaultLoThreshold uint16 = 0x8000
+	DefaultHiThreshold uint16 = 0x7fff
+
+	// Config register fields
+	configOS        uint16 = 0x8000
+	configMuxMask   uint16 = 0x7000
+	configMuxShift         = 12
+	configPGAMask   uint16 = 0x0e00
+	configPGAShift         = 9
+	configMode      uint16 = 0x0100 // 1=single-shot, 0=continuous
+	configDRMask    uint16 = 0x00e0
+	configDRShift          = 5
+	configCompMode  uint16 = 0x0010 // 1=window comparator
+	configCompQueue uint16 = 0x0003 // 3=comparator disabled
+)
+
+var (
+	// Full scale range (in volts) per PGA setting
+	fullScaleRange = [8]float64{6.144, 4.096, 2.048, 1.024, 0.512, 0.256, 0.256, 0.256}
+	// Samples per second per data rate setting
+	dataRates = [8]int{8, 16, 32, 64, 128, 250, 475, 860}
+	// Positive & negative input channel per MUX setting (-1 == GND)
+	muxInputs = [8][2]int{{0, 1}, {0, 3}, {1, 3}, {2, 3}, {0, -1}, {1, -1}, {2, -1}, {3, -1}}
+)
+
+// Waveform returns the voltage of an input at the given time since
+// the simulator was created.
+type Waveform func(t time.Duration) float64
+
+// Constant returns a waveform with a constant voltage.
+func Constant(volts float64) Waveform {
+	return func(time.Duration) float64 { return volts }
+}
+
+// Steps returns a waveform that steps through the given voltages,
+// holding each for the given interval.
+// The last voltage is held forever.
+func Steps(interval time.Duration, volts ...float64) Waveform {
+	return func(t time.Duration) float64 {
+		if len(volts) == 0 {
+			return 0
+		}
+		idx := int(t / interval)
+		if idx >= len(volts) {
+			idx = len(volts) - 1
+		}
+		return volts[idx]
+	}
+}
+
+// Simulator models a single ADS1115 device.
+type Simulator struct {
+	mutex sync.Mutex
+
+	now   func() time.Time
+	start time.Time
+
+	pointer     uint8
+	config      uint16
+	conversion  uint16
+	loThreshold uint16
+	hiThreshold uint16
+	alert       bool
+
+	converting      bool
+	conversionStart time.Time
+	waveforms       [ChannelCount]Waveform
+
+	offline          bool
+	stallConversions bool
+	conversions      int
+}
+
+// New initializes a new simulator in power-up state using the system clock.
+func New() *Simulator {
+	return NewWithClock(time.Now)
+}
+
+// NewWithClock initializes a new simulator in power-up state using the given clock.
+func NewWithClock(now func() time.Time) *Simulator {
+	s := &Simulator{
+		now:   now,
+		start: now(),
+	}
+	s.reset()
+	for i := range s.waveforms {
+		s.waveforms[i] = Constant(0)
+	}
+	return s
+}
+
+// Reset all registers to their power-up state, like a general call reset.
+func (s *Simulator) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reset()
+}
+
+// SetWaveform sets the input waveform of the channel (0..3).
+func (s *Simulator) SetWaveform(channel int, w Waveform) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.waveforms[channel] = w
+}
+
+// SetVoltage sets the input of the channel (0..3) to a constant voltage.
+func (s *Simulator) SetVoltage(channel int, volts float64) {
+	s.SetWaveform(channel, Constant(volts))
+}
+
+// SetOffline makes the device stop (or resume) acknowledging transactions.
+func (s *Simulator) SetOffline(offline bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.offline = offline
+}
+
+// SetStallConversions makes conversions never complete (or complete again),
+// so the OS bit keeps reporting busy.
+func (s *Simulator) SetStallConversions(stall bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stallConversions = stall
+}
+
+// Config returns the current value of the config register.
+func (s *Simulator) Config() uint16 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.update()
+	return s.configValue()
+}
+
+// Conversions returns the number of completed conversions.
+func (s *Simulator) Conversions() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.update()
+	return s.conversions
+}
+
+// Alert returns true when the ALERT/RDY pin is asserted.
+func (s *Simulator) Alert() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.update()
+	return s.alert
+}
+
+// Tx handles a single I2C transaction targeting this device.
+func (s *Simulator) Tx(w, r []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.offline {
+		return mockbus.ErrNACK
+	}
+	s.update()
+	if len(w) > 0 {
+		if w[0] > RegHiThreshold {
+			return fmt.Errorf("Invalid register pointer 0x%02x", w[0])
+		}
+		s.pointer = w[0]
+		switch len(w) {
+		case 1:
+			// Only set pointer
+		case 3:
+			s.writeRegister(s.pointer, (uint16(w[1])<<8)|uint16(w[2]))
+		default:
+			return fmt.Errorf("Invalid write length %d", len(w))
+		}
+	}
+	if len(r) > 0 {
+		value := s.readRegister(s.pointer)
+		for i := range r {
+			// MSB first, repeated for longer reads
+			if i%2 == 0 {
+				r[i] = uint8(value >> 8)
+			} else {
+				r[i] = uint8(value)
+			}
+		}
+	}
+	return nil
+}
+
+// Reset to power-up state
+func (s *Simulator) reset() {
+	s.pointer = RegConversion
+	s.config = DefaultConfig &^ configOS
+	s.conversion = 0
+	s.loThreshold = DefaultLoThreshold
+	s.hiThreshold = DefaultHiThreshold
+	s.converting = false
+	s.alert = false
+}
+
+// Returns the config register as read from the device
+func (s *Simulator) configValue() uint16 {
+	if s.converting {
+		return s.config &^ configOS
+	}
+	return s.config | configOS
+}
+
+// Read a register
+func (s *Simulator) readRegister(reg uint8) uint16 {
+	switch reg {
+	case RegConversion:
+		return s.conversion
+	case RegConfig:
+		return s.configValue()
+	case RegLoThreshold:
+		return s.loThreshold
+	default:
+		return s.hiThreshold
+	}
+}
+
+// Write a register
+func (s *Simulator) writeRegister(reg uint8, value uint16) {
+	switch reg {
+	case RegConversion:
+		// Read-only
+	case RegConfig:
+		s.config = value &^ configOS
+		if value&configMode == 0 {
+			// Continuous mode
+			s.startConversion()
+		} else if value&configOS != 0 && !s.converting {
+			// Start single-shot conversion
+			s.startConversion()
+		}
+	case RegLoThreshold:
+		s.loThreshold = value
+	case RegHiThreshold:
+		s.hiThreshold = value
+	}
+}
+
+// Start a new conversion
+func (s *Simulator) startConversion() {
+	s.converting = true
+	s.conversionStart = s.now()
+}
+
+// Returns the duration of a single conversion
+func (s *Simulator) conversionTime() time.Duration {
+	rate := dataRates[(s.config&configDRMask)>>configDRShift]
+	return time.Second / time.Duration(rate)
+}
+
+// Complete conversions that have finished by now
+func (s *Simulator) update() {
+	for s.converting && !s.stallConversions {
+		end := s.conversionStart.Add(s.conversionTime())
+		if s.now().Before(end) {
+			return
+		}
+		s.completeConversion(end)
+		if s.config&configMode == 0 {
+			// Continuous mode: start next conversion
+			s.conversionStart = end
+		} else {
+			s.converting = false
+		}
+	}
+}
+
+// Complete a conversion that ended at the given time
+func (s *Simulator) completeConversion(end time.Time) {
+	mux := (s.config & configMuxMask) >> configMuxShift
+	inputs := muxInputs[mux]
+	t := end.Sub(s.start)
+	volts := s.waveforms[inputs[0]](t)
+	if inputs[1] >= 0 {
+		volts -= s.waveforms[inputs[1]](t)
+	}
+	fsr := fullScaleRange[(s.config&configPGAMask)>>configPGAShift]
+	raw := volts / fsr * 32768
+	switch {
+	case raw >= 32767:
+		raw = 32767
+	case raw <= -32768:
+		raw = -32768
+	}
+	value := int16(raw)
+	s.conversion = uint16(value)
+	s.conversions++
+	s.updateComparator(value)
+}
+
+// Update the ALERT/RDY state after a conversion
+func (s *Simulator) updateComparator(value int16) {
+	lo, hi := int16(s.loThreshold), int16(s.hiThreshold)
+	switch {
+	case s.config&configCompQueue == configCompQueue:
+		// Comparator disabled
+		s.alert = false
+	case s.hiThreshold&0x8000 != 0 && s.loThreshold&0x8000 == 0:
+		// Conversion ready mode
+		s.alert = true
+	case s.config&configCompMode != 0:
+		// Window comparator
+		s.alert = value > hi || value < lo
+	default:
+		// Traditional comparator with hysteresis
+		if value > hi {
+			s.alert = true
+		} else if value < lo {
+			s.alert = false
+		}
+	}
+}
@@ -2,12 +2,13 @@ package ads1115
 
 import (
 	"fmt"
-	"machine"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus"
 )
 
 // Device implements access to an ADS1115 device.
 type Device struct {
-	i2c        *machine.I2C
+	i2c        i2cbus.Bus
 	i2cAddress uint8
 }
 
@@ -49,7 +50,7 @@ const (
 )
 
 // New initializes a new device attached to given I2C bus.
-func New(i2c *machine.I2C, i2cAddress uint8) *Device {
+func New(i2c i2cbus.Bus, i2cAddress uint8) *Device {
 	return &Device{
 		i2c:        i2c,
 		i2cAddress: i2cAddress,
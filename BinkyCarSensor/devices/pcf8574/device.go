@@ -2,17 +2,18 @@ package pcf8574
 
 import (
 	"fmt"
-	"machine"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus"
 )
 
 // Device implements access to an PCF8574 device.
 type Device struct {
-	i2c        *machine.I2C
+	i2c        i2cbus.Bus
 	i2cAddress uint8
 }
 
 // New initializes a new device attached to given I2C bus.
-func New(i2c *machine.I2C, i2cAddress uint8) *Device {
+func New(i2c i2cbus.Bus, i2cAddress uint8) *Device {
 	return &Device{
 		i2c:        i2c,
 		i2cAddress: i2cAddress,
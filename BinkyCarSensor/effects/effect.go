@@ -0,0 +1,191 @@
+// Package effects implements light effects (blink, alternate, fade, flicker
+// & dim) that the firmware runs on its on-pcb IO pins.
+//
+// An Effect returns a brightness level (0-255) for a given time.
+// Duty converts a level into a gamma-corrected PWM duty cycle.
+package effects
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Params holds the parameters of a light effect.
+type Params struct {
+	// Kind of effect (protocol.EffectXyz)
+	Kind uint8
+	// Maximum brightness (0-255)
+	Brightness uint8
+	// On time in milliseconds (fade in time, or minimum interval for flicker)
+	OnTime uint16
+	// Off time in milliseconds (fade out time, or maximum interval for flicker)
+	OffTime uint16
+	// Index of the pin driven in opposite phase (EffectAlternate only)
+	Partner uint8
+}
+
+// Validate the parameters of an effect on the pin with given index,
+// returning an error if invalid.
+func (p Params) Validate(pin uint8) error {
+	switch p.Kind {
+	case protocol.EffectNone, protocol.EffectDim:
+		// No timing
+	case protocol.EffectBlink, protocol.EffectFade:
+		if p.OnTime == 0 && p.OffTime == 0 {
+			return fmt.Errorf("OnTime or OffTime must be set")
+		}
+	case protocol.EffectAlternate:
+		if p.OnTime == 0 || p.OffTime == 0 {
+			return fmt.Errorf("OnTime and OffTime must be set")
+		}
+		if p.Partner >= protocol.IOPinCount || p.Partner == pin {
+			return fmt.Errorf("Invalid partner pin: %d", p.Partner)
+		}
+	case protocol.EffectFlicker:
+		if p.OnTime == 0 || p.OffTime < p.OnTime {
+			return fmt.Errorf("OnTime must be > 0 and OffTime must be >= OnTime")
+		}
+	default:
+		return fmt.Errorf("Invalid effect kind: %d", p.Kind)
+	}
+	return nil
+}
+
+// EncodeRecord encodes the parameters as register record of
+// protocol.EffectRecordSize bytes.
+func (p Params) EncodeRecord() []byte {
+	return []byte{
+		p.Kind,
+		p.Brightness,
+		uint8(p.OnTime), uint8(p.OnTime >> 8),
+		uint8(p.OffTime), uint8(p.OffTime >> 8),
+		p.Partner,
+	}
+}
+
+// DecodeRecord decodes a register record of protocol.EffectRecordSize bytes.
+// A single byte record (EffectNone) is accepted to stop an effect.
+func DecodeRecord(record []byte) (Params, error) {
+	if len(record) == 1 && record[0] == protocol.EffectNone {
+		return Params{}, nil
+	}
+	if len(record) < protocol.EffectRecordSize {
+		return Params{}, fmt.Errorf("Effect record too short: %d", len(record))
+	}
+	return Params{
+		Kind:       record[0],
+		Brightness: record[1],
+		OnTime:     uint16(record[2]) | (uint16(record[3]) << 8),
+		OffTime:    uint16(record[4]) | (uint16(record[5]) << 8),
+		Partner:    record[6],
+	}, nil
+}
+
+// Effect holds the state of a single running effect.
+type Effect struct {
+	params     Params
+	start      uint32 // Time the effect started (ms)
+	random     uint32 // State of the random generator (flicker)
+	level      uint8  // Current level (flicker)
+	nextChange uint32 // Time of the next level change (flicker)
+}
+
+// New starts an effect with given parameters at the given time (ms).
+// The seed initializes the random generator used by flicker effects.
+func New(params Params, now uint32, seed uint32) *Effect {
+	if seed == 0 {
+		seed = 1
+	}
+	return &Effect{
+		params:     params,
+		start:      now,
+		random:     seed,
+		nextChange: now,
+	}
+}
+
+// Params returns the parameters of the effect.
+func (e *Effect) Params() Params {
+	return e.params
+}
+
+// Level returns the brightness level (0-255) of the pin at the given time (ms).
+func (e *Effect) Level(now uint32) uint8 {
+	p := e.params
+	t := now - e.start
+	switch p.Kind {
+	case protocol.EffectBlink, protocol.EffectAlternate:
+		if e.inOnPhase(t) {
+			return p.Brightness
+		}
+		return 0
+	case protocol.EffectFade:
+		cycle := uint32(p.OnTime) + uint32(p.OffTime)
+		t %= cycle
+		if t < uint32(p.OnTime) {
+			return uint8(uint32(p.Brightness) * t / uint32(p.OnTime))
+		}
+		return uint8(uint32(p.Brightness) * (cycle - t) / uint32(p.OffTime))
+	case protocol.EffectFlicker:
+		if int32(now-e.nextChange) >= 0 {
+			e.level = uint8(e.next() % (uint32(p.Brightness) + 1))
+			interval := uint32(p.OnTime) + e.next()%(uint32(p.OffTime-p.OnTime)+1)
+			e.nextChange = now + interval
+		}
+		return e.level
+	case protocol.EffectDim:
+		return p.Brightness
+	default:
+		return 0
+	}
+}
+
+// PartnerLevel returns the brightness level (0-255) of the partner pin
+// at the given time (ms).
+// Only EffectAlternate drives a partner pin.
+func (e *Effect) PartnerLevel(now uint32) uint8 {
+	if e.params.Kind != protocol.EffectAlternate || e.inOnPhase(now-e.start) {
+		return 0
+	}
+	return e.params.Brightness
+}
+
+// Returns true if time t (ms since start) is in the on phase of a blink cycle
+func (e *Effect) inOnPhase(t uint32) bool {
+	cycle := uint32(e.params.OnTime) + uint32(e.params.OffTime)
+	return t%cycle < uint32(e.params.OnTime)
+}
+
+// Returns the next pseudo random number (xorshift32)
+func (e *Effect) next() uint32 {
+	x := e.random
+	x ^= x << 13
+	x ^= x >> 17
+	x ^= x << 5
+	e.random = x
+	return x
+}
+
+const (
+	// Gamma used to convert brightness levels into duty cycles
+	gamma = 2.2
+)
+
+var (
+	// Duty cycle of every brightness level
+	gammaTable [256]uint16
+)
+
+func init() {
+	for level := range gammaTable {
+		gammaTable[level] = uint16(math.Round(math.Pow(float64(level)/255, gamma) * 0xffff))
+	}
+}
+
+// Duty returns the gamma-corrected PWM duty cycle (0xffff = fully on)
+// of the given brightness level.
+func Duty(level uint8) uint16 {
+	return gammaTable[level]
+}
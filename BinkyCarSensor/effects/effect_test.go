@@ -0,0 +1,144 @@
+package effects
+
+import (
+	"testing"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+func TestLevel(t *testing.T) {
+	blink := Params{Kind: protocol.EffectBlink, Brightness: 200, OnTime: 100, OffTime: 300}
+	alternate := Params{Kind: protocol.EffectAlternate, Brightness: 150, OnTime: 100, OffTime: 100, Partner: 1}
+	fade := Params{Kind: protocol.EffectFade, Brightness: 200, OnTime: 100, OffTime: 400}
+	dim := Params{Kind: protocol.EffectDim, Brightness: 40}
+
+	tests := []struct {
+		name    string
+		params  Params
+		start   uint32
+		now     uint32
+		level   uint8
+		partner uint8
+	}{
+		{"blink start", blink, 1000, 1000, 200, 0},
+		{"blink end of on", blink, 1000, 1099, 200, 0},
+		{"blink off", blink, 1000, 1100, 0, 0},
+		{"blink end of off", blink, 1000, 1399, 0, 0},
+		{"blink next cycle", blink, 1000, 1400, 200, 0},
+		{"blink across timer wrap (on)", blink, 0xffffffc0, 0x00000020, 200, 0},
+		{"blink across timer wrap (off)", blink, 0xffffffc0, 0x00000030, 0, 0},
+		{"alternate on", alternate, 0, 50, 150, 0},
+		{"alternate off", alternate, 0, 150, 0, 150},
+		{"alternate next cycle", alternate, 0, 250, 150, 0},
+		{"fade start", fade, 0, 0, 0, 0},
+		{"fade in halfway", fade, 0, 50, 100, 0},
+		{"fade top", fade, 0, 100, 200, 0},
+		{"fade out halfway", fade, 0, 300, 100, 0},
+		{"fade out end", fade, 0, 499, 0, 0},
+		{"fade next cycle", fade, 0, 525, 50, 0},
+		{"dim", dim, 0, 12345, 40, 0},
+		{"none", Params{}, 0, 100, 0, 0},
+	}
+	for _, test := range tests {
+		e := New(test.params, test.start, 1)
+		if level := e.Level(test.now); level != test.level {
+			t.Errorf("%s: expected level %d, got %d", test.name, test.level, level)
+		}
+		if partner := e.PartnerLevel(test.now); partner != test.partner {
+			t.Errorf("%s: expected partner level %d, got %d", test.name, test.partner, partner)
+		}
+	}
+}
+
+func TestFlicker(t *testing.T) {
+	params := Params{Kind: protocol.EffectFlicker, Brightness: 180, OnTime: 20, OffTime: 80}
+	e := New(params, 1000, 1234)
+	same := New(params, 1000, 1234)
+	other := New(params, 1000, 4321)
+
+	lastChange := uint32(1000)
+	last := e.Level(1000)
+	same.Level(1000)
+	other.Level(1000)
+	changes, differences := 0, 0
+	for now := uint32(1001); now < 6000; now++ {
+		level := e.Level(now)
+		if level > params.Brightness {
+			t.Fatalf("%d: level %d exceeds brightness", now, level)
+		}
+		if level != same.Level(now) {
+			t.Fatalf("%d: expected same level for same seed", now)
+		}
+		if level != other.Level(now) {
+			differences++
+		}
+		if level != last {
+			if now-lastChange < uint32(params.OnTime) {
+				t.Fatalf("%d: level changed after %dms, expected at least %dms", now, now-lastChange, params.OnTime)
+			}
+			changes++
+			lastChange, last = now, level
+		} else if now-lastChange > 4*uint32(params.OffTime) {
+			t.Fatalf("%d: level unchanged for %dms", now, now-lastChange)
+		}
+	}
+	// 5s with intervals of 20-80ms
+	if changes < 5000/80/2 || changes > 5000/20 {
+		t.Errorf("expected %d-%d level changes, got %d", 5000/80/2, 5000/20, changes)
+	}
+	if differences == 0 {
+		t.Error("expected different seeds to flicker differently")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+		valid  bool
+	}{
+		{"none", Params{}, true},
+		{"dim", Params{Kind: protocol.EffectDim, Brightness: 10}, true},
+		{"blink", Params{Kind: protocol.EffectBlink, OnTime: 100}, true},
+		{"blink without timing", Params{Kind: protocol.EffectBlink}, false},
+		{"fade", Params{Kind: protocol.EffectFade, OffTime: 100}, true},
+		{"alternate", Params{Kind: protocol.EffectAlternate, OnTime: 100, OffTime: 100, Partner: 3}, true},
+		{"alternate without off time", Params{Kind: protocol.EffectAlternate, OnTime: 100, Partner: 3}, false},
+		{"alternate with itself", Params{Kind: protocol.EffectAlternate, OnTime: 100, OffTime: 100, Partner: 2}, false},
+		{"alternate with invalid pin", Params{Kind: protocol.EffectAlternate, OnTime: 100, OffTime: 100, Partner: protocol.IOPinCount}, false},
+		{"flicker", Params{Kind: protocol.EffectFlicker, OnTime: 20, OffTime: 20}, true},
+		{"flicker with short max interval", Params{Kind: protocol.EffectFlicker, OnTime: 20, OffTime: 10}, false},
+		{"invalid kind", Params{Kind: 0xff}, false},
+	}
+	for _, test := range tests {
+		if err := test.params.Validate(2); (err == nil) != test.valid {
+			t.Errorf("%s: expected valid=%v, got %v", test.name, test.valid, err)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	expected := Params{Kind: protocol.EffectAlternate, Brightness: 99, OnTime: 1234, OffTime: 4321, Partner: 5}
+	if p, err := DecodeRecord(expected.EncodeRecord()); err != nil {
+		t.Errorf("DecodeRecord failed: %s", err)
+	} else if p != expected {
+		t.Errorf("expected %+v, got %+v", expected, p)
+	}
+	if p, err := DecodeRecord([]byte{protocol.EffectNone}); err != nil || p != (Params{}) {
+		t.Errorf("expected single byte record to stop the effect, got %+v (%v)", p, err)
+	}
+	if _, err := DecodeRecord([]byte{protocol.EffectBlink, 1}); err == nil {
+		t.Error("expected short record to be rejected")
+	}
+}
+
+func TestDuty(t *testing.T) {
+	if Duty(0) != 0 || Duty(255) != 0xffff {
+		t.Errorf("expected duty 0 & 0xffff at the ends, got %d & %d", Duty(0), Duty(255))
+	}
+	for level := 1; level < 256; level++ {
+		if Duty(uint8(level)) < Duty(uint8(level-1)) {
+			t.Fatalf("duty of level %d is lower than of level %d", level, level-1)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"image/color"
+	"sync/atomic"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pwmslices"
+	"tinygo.org/x/drivers/ws2812"
+)
+
+var (
+	// Set while outputs are in their safe state because of communication loss
+	failsafeActive atomic.Bool
+)
+
+// commWatchdog keeps track of the time of the last valid transaction on the
+// incoming i2c port.
+type commWatchdog struct {
+	lastTransaction atomic.Uint32 // Milliseconds since boot
+}
+
+// Record a valid transaction
+func (w *commWatchdog) feed() {
+	w.lastTransaction.Store(millisSinceBoot())
+}
+
+// Returns the time since the last valid transaction
+func (w *commWatchdog) elapsed() time.Duration {
+	return time.Duration(millisSinceBoot()-w.lastTransaction.Load()) * time.Millisecond
+}
+
+// Returns the neopixel color to show, given the color of the sensor status
+func statusColor(c color.RGBA) color.RGBA {
+	if failsafeActive.Load() {
+		return colorFailsafe
+	}
+	return c
+}
+
+// statusLed shows the sensor status on the neopixel, unless the failsafe is active.
+type statusLed struct {
+	led     ws2812.Device
+	color   color.RGBA      // Color of the sensor status
+	updates <-chan struct{} // Signals a change of the failsafe state
+}
+
+// Show the given sensor status color
+func (l *statusLed) show(c color.RGBA) {
+	l.color = c
+	l.led.WriteColors([]color.RGBA{statusColor(c)})
+}
+
+// Sleep for the given duration, showing changes of the failsafe state right away
+func (l *statusLed) sleep(d time.Duration) {
+	deadline := time.After(d)
+	for {
+		select {
+		case <-l.updates:
+			l.show(l.color)
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// Ask the owner of the neopixel to show the failsafe state
+func (r *i2cRegisters) updateStatusLed() {
+	select {
+	case r.ledUpdates <- struct{}{}:
+	default:
+		// Update already pending
+	}
+}
+
+// Trigger the failsafe when no valid transaction arrived in time
+func (r *i2cRegisters) checkFailsafe() {
+	timeout := r.config.Failsafe.Timeout
+	if timeout == 0 || r.failsafeFlags&protocol.FailsafeFlagActive != 0 {
+		return
+	}
+	if r.watchdog.elapsed() >= timeout {
+		r.enterFailsafe()
+	}
+}
+
+// Drive all outputs to their safe state
+func (r *i2cRegisters) enterFailsafe() {
+	println("Communication lost, entering failsafe")
+	r.failsafeFlags |= protocol.FailsafeFlagActive | protocol.FailsafeFlagTriggered
+	failsafeActive.Store(true)
+	r.updateStatusLed()
+
+	fs := r.config.Failsafe
+	for idx, isPWM := range r.isPWM {
+		if !isPWM {
+			continue
+		}
+		if r.isServo[idx] {
+			r.setServoTarget(uint8(idx), fs.PWM[idx])
+		} else {
+			r.setPWM(uint8(idx), pwmslices.DutyOfByte(fs.PWM[idx]))
+		}
+	}
+	r.setOutputs(fs.Outputs)
+	for idx := uint8(0); idx < r.i2cOutputBitsCount/8; idx++ {
+		r.setPCFOutputs(idx, fs.PCFOutputs[idx])
+	}
+}
+
+// Communication resumed, outputs stay in their safe state until changed by the host
+func (r *i2cRegisters) leaveFailsafe() {
+	if r.failsafeFlags&protocol.FailsafeFlagActive == 0 {
+		return
+	}
+	println("Communication resumed, leaving failsafe")
+	r.failsafeFlags &^= protocol.FailsafeFlagActive
+	failsafeActive.Store(false)
+	r.updateStatusLed()
+}
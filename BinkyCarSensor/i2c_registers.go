@@ -0,0 +1,895 @@
+package main
+
+import (
+	"machine"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/detection"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/effects"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus/manager"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/inputs"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pairs"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pulse"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pwmslices"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/servo"
+)
+
+const (
+	// Interval between updates of servos & light effects (one servo period)
+	outputUpdateInterval = time.Second / servo.Frequency
+)
+
+// i2cRegisters holds the state of all registers exposed on the
+// incoming i2c port.
+// It lives as long as the firmware runs, so its state survives
+// restarts of the i2c listener.
+type i2cRegisters struct {
+	i2c                   *machine.I2C
+	io0Locked             bool // Set when IO1 is pulled down to select the alternate address
+	carSensorStateChanges <-chan carSensorStatus
+	outputStatus          chan<- pcfOutput
+	outputResults         <-chan pcfOutput
+	carSensorBitsCount    uint8 // Number of car sensors found so far
+	i2cOutputBitsCount    uint8
+	bus                   *manager.Manager
+
+	configStore      *config.Store
+	config           config.Config
+	savedConfig      config.Config // Configuration as last saved in (or loaded from) flash
+	configVersion    uint8
+	configFlags      uint8
+	detectionChanges chan config.Detection
+	watchdog         *commWatchdog
+	ledUpdates       chan<- struct{} // Asks the owner of the neopixel to show the failsafe state
+	supervisor       *loopSupervisor
+	failsafeFlags    uint8
+
+	lastOutputVals          [1 + protocol.MaxPCFDevices]uint8
+	lastRequestReq          uint8
+	pwm                     *pwmOutputs
+	outputs                 uint8                                   // Value driven to the on-pcb output pins
+	outputGroups            [1 + protocol.MaxPCFDevices]pulse.Group // Pulse state of on-pcb outputs & PCF8574 devices
+	selectedPulseOutput     uint8
+	appliedOutputs          uint8                         // Level last applied to the on-pcb output pins
+	pcfApplied              [protocol.MaxPCFDevices]uint8 // Value last written to each PCF8574 device
+	pcfFlags                [protocol.MaxPCFDevices]uint8 // Output status flags of each PCF8574 device
+	pcfPending              [protocol.MaxPCFDevices]uint8 // Number of queued writes to each PCF8574 device
+	outputsWritten          bool
+	isPWM                   [protocol.IOPinCount]bool
+	pwmDuty                 [protocol.IOPinCount]uint16
+	pwmFrequency            [protocol.IOPinCount]uint16
+	isServo                 [protocol.IOPinCount]bool
+	servos                  [protocol.IOPinCount]*servo.Servo
+	isEffect                [protocol.IOPinCount]bool
+	effects                 [protocol.IOPinCount]*effects.Effect // Nil for pins without effect or driven by partner
+	isInput                 [protocol.IOPinCount]bool
+	digitalModes            [protocol.IOPinCount]uint8 // Mode of pins not in PWM or input mode (output, open-drain or disabled)
+	pinErrors               uint8                      // Pins whose last request was rejected
+	lastPinError            uint8                      // Reason of the last rejection
+	inputs                  inputs.Group               // Debounced state of inputs
+	inputState              detection.State            // Latched state of inputs
+	sensorState             detection.State
+	pairs                   pairs.Tracker // Speed & direction of car sensor pairs
+	selectedDetectionSensor uint8
+	selectedBusDevice       uint8
+
+	calibrationRequests       chan uint16
+	calibrationResults        <-chan calibrationResult
+	calibrating               uint16 // Car sensors being calibrated
+	calibrationRequested      uint16 // Car sensors calibrated on request, awaiting their result
+	calibrationFinished       uint16 // Car sensors calibrated on request, to save once all requested are done
+	selectedCalibrationSensor uint8
+	selectedDetectorSensor    uint8
+}
+
+// Initialize the i2c registers.
+func newI2CRegisters(i2c *machine.I2C, io0Locked bool,
+	carSensorStateChanges <-chan carSensorStatus, outputStatus chan<- pcfOutput, outputResults <-chan pcfOutput,
+	i2cOutputBitsCount uint8, bus *manager.Manager,
+	configStore *config.Store, cfg config.Config, configVersion uint8,
+	detectionChanges chan config.Detection, calibrationRequests chan uint16, calibrationResults <-chan calibrationResult,
+	watchdog *commWatchdog, ledUpdates chan<- struct{}, supervisor *loopSupervisor) *i2cRegisters {
+	r := &i2cRegisters{
+		i2c:                     i2c,
+		io0Locked:               io0Locked,
+		carSensorStateChanges:   carSensorStateChanges,
+		outputStatus:            outputStatus,
+		outputResults:           outputResults,
+		i2cOutputBitsCount:      i2cOutputBitsCount,
+		bus:                     bus,
+		pwm:                     newPWMOutputs(),
+		configStore:             configStore,
+		config:                  cfg,
+		savedConfig:             cfg,
+		configVersion:           configVersion,
+		detectionChanges:        detectionChanges,
+		calibrationRequests:     calibrationRequests,
+		calibrationResults:      calibrationResults,
+		watchdog:                watchdog,
+		ledUpdates:              ledUpdates,
+		supervisor:              supervisor,
+		selectedDetectionSensor: protocol.DetectionGlobal,
+	}
+	for idx := range r.servos {
+		r.servos[idx] = servo.New(cfg.Servos[idx])
+	}
+	r.configurePulses()
+	r.configurePairs()
+	if configVersion != 0 {
+		r.configFlags |= protocol.ConfigFlagLoaded
+		if configVersion != config.CurrentVersion {
+			r.configFlags |= protocol.ConfigFlagMigrated
+		}
+	}
+	return r
+}
+
+// Apply the power-on modes & values of all outputs from the configuration.
+func (r *i2cRegisters) applyPowerOnDefaults() {
+	r.configureInputs()
+	for idx, mode := range r.config.PinModes {
+		if !r.isInput[idx] {
+			r.setPinMode(uint8(idx), mode)
+		}
+	}
+	r.setOutputs(r.config.OutputDefaults)
+	for idx, value := range r.config.PCFOutputDefaults {
+		if value != 0 {
+			r.setPCFOutputs(uint8(idx), value)
+		}
+	}
+}
+
+// Process incoming i2c events & status changes
+func (r *i2cRegisters) run(events <-chan incomingI2CEvent) {
+	outputTicker := time.NewTicker(outputUpdateInterval)
+	for {
+		r.supervisor.alive(protocol.LoopRegisters)
+		select {
+		case <-outputTicker.C:
+			r.updateServos()
+			r.updateEffects()
+			r.updatePulses()
+			r.updateInputs()
+			r.checkFailsafe()
+		case x := <-r.carSensorStateChanges:
+			if x.Count != r.carSensorBitsCount {
+				println("Update sensor count: ", x.Count)
+				r.carSensorBitsCount = x.Count
+			}
+			r.sensorState.SetPolarity(x.Polarity)
+			now := millisSinceBoot()
+			if r.sensorState.Update(x.State, now) {
+				println("Update sensor status: ", x.State)
+			}
+			r.pairs.Update(x.State, now)
+			r.calibrating = x.Calibrating
+		case result := <-r.calibrationResults:
+			r.calibrationDone(result)
+		case result := <-r.outputResults:
+			r.pcfOutputDone(result)
+		case evt := <-events:
+			// Handle event
+			switch evt.Event {
+			case machine.I2CReceive:
+				if evt.HasRegister {
+					r.leaveFailsafe()
+				}
+				r.receive(evt)
+			case machine.I2CRequest:
+				r.leaveFailsafe()
+				r.request(evt)
+			case machine.I2CFinish:
+				// No response needed
+			}
+		}
+	}
+}
+
+// Handle a register write
+func (r *i2cRegisters) receive(evt incomingI2CEvent) {
+	if evt.HasValue && evt.Register >= protocol.RegOutput && evt.Register < protocol.RegOutputI2C7 {
+		outputIndex := evt.Register - protocol.RegOutput
+		if r.lastOutputVals[outputIndex] != evt.Value {
+			println("I2C:Receive Output ", outputIndex, evt.Value)
+			r.lastOutputVals[outputIndex] = evt.Value
+		}
+	}
+	switch evt.Register {
+	case protocol.RegOutput:
+		if evt.HasValue {
+			r.setOutputs(evt.Value)
+		}
+	case protocol.RegOutputI2C0, protocol.RegOutputI2C1, protocol.RegOutputI2C2, protocol.RegOutputI2C3, protocol.RegOutputI2C4, protocol.RegOutputI2C5, protocol.RegOutputI2C6, protocol.RegOutputI2C7:
+		if evt.HasValue {
+			r.setPCFOutputs(evt.Register-protocol.RegOutputI2C0, evt.Value)
+		}
+	case protocol.RegConfigurePWM0, protocol.RegConfigurePWM1, protocol.RegConfigurePWM2, protocol.RegConfigurePWM3, protocol.RegConfigurePWM4, protocol.RegConfigurePWM5, protocol.RegConfigurePWM6, protocol.RegConfigurePWM7:
+		if evt.HasValue {
+			r.setPWM(evt.Register-protocol.RegConfigurePWM0, pwmslices.DutyOfByte(evt.Value))
+		}
+	case protocol.RegPWMDuty0, protocol.RegPWMDuty1, protocol.RegPWMDuty2, protocol.RegPWMDuty3, protocol.RegPWMDuty4, protocol.RegPWMDuty5, protocol.RegPWMDuty6, protocol.RegPWMDuty7:
+		if evt.ValueCount >= 2 {
+			r.setPWM(evt.Register-protocol.RegPWMDuty0, evt.Uint16())
+		}
+	case protocol.RegPWMFrequency0, protocol.RegPWMFrequency1, protocol.RegPWMFrequency2, protocol.RegPWMFrequency3, protocol.RegPWMFrequency4, protocol.RegPWMFrequency5, protocol.RegPWMFrequency6, protocol.RegPWMFrequency7:
+		if evt.ValueCount >= 2 {
+			r.setPWMFrequency(evt.Register-protocol.RegPWMFrequency0, evt.Uint16())
+		}
+	case protocol.RegServoTarget0, protocol.RegServoTarget1, protocol.RegServoTarget2, protocol.RegServoTarget3, protocol.RegServoTarget4, protocol.RegServoTarget5, protocol.RegServoTarget6, protocol.RegServoTarget7:
+		if evt.HasValue {
+			r.setServoTarget(evt.Register-protocol.RegServoTarget0, evt.Value)
+		}
+	case protocol.RegServoConfig0, protocol.RegServoConfig1, protocol.RegServoConfig2, protocol.RegServoConfig3, protocol.RegServoConfig4, protocol.RegServoConfig5, protocol.RegServoConfig6, protocol.RegServoConfig7:
+		ioIndex := evt.Register - protocol.RegServoConfig0
+		if evt.ValueCount == 0 {
+			// Select only (readback)
+		} else if params, err := config.DecodeServoRecord(evt.Values[:evt.ValueCount]); err != nil {
+			println("Invalid servo parameters: ", err.Error())
+		} else {
+			update := r.config
+			update.Servos[ioIndex] = params
+			if r.updateConfig(update) {
+				r.servos[ioIndex].Configure(params)
+			}
+		}
+	case protocol.RegInputConfig0, protocol.RegInputConfig1, protocol.RegInputConfig2, protocol.RegInputConfig3, protocol.RegInputConfig4, protocol.RegInputConfig5, protocol.RegInputConfig6, protocol.RegInputConfig7:
+		ioIndex := evt.Register - protocol.RegInputConfig0
+		if !evt.HasValue {
+			// Select only
+		} else if params, err := config.DecodeInputRecord(evt.Values[:evt.ValueCount]); err != nil {
+			println("Invalid input settings: ", err.Error())
+		} else {
+			update := r.config
+			update.Inputs[ioIndex] = params
+			if r.updateConfig(update) {
+				r.setInput(ioIndex, params)
+			}
+		}
+	case protocol.RegPairConfig0, protocol.RegPairConfig1, protocol.RegPairConfig2, protocol.RegPairConfig3:
+		pairIndex := evt.Register - protocol.RegPairConfig0
+		if !evt.HasValue {
+			// Select only
+		} else if params, err := config.DecodePairRecord(evt.Values[:evt.ValueCount]); err != nil {
+			println("Invalid sensor pair: ", err.Error())
+		} else {
+			update := r.config
+			update.Pairs[pairIndex] = params
+			if r.updateConfig(update) {
+				r.pairs.Configure(int(pairIndex), params)
+			}
+		}
+	case protocol.RegPinMode0, protocol.RegPinMode1, protocol.RegPinMode2, protocol.RegPinMode3, protocol.RegPinMode4, protocol.RegPinMode5, protocol.RegPinMode6, protocol.RegPinMode7:
+		if evt.HasValue {
+			r.setPinMode(evt.Register-protocol.RegPinMode0, evt.Value)
+		}
+	case protocol.RegPinErrors:
+		if evt.HasValue {
+			r.pinErrors &^= evt.Value
+			if r.pinErrors == 0 {
+				r.lastPinError = protocol.PinErrorNone
+			}
+		}
+	case protocol.RegInputAckEdges:
+		if evt.HasValue {
+			r.inputState.AckEdges(uint16(evt.Value))
+		}
+	case protocol.RegEffect0, protocol.RegEffect1, protocol.RegEffect2, protocol.RegEffect3, protocol.RegEffect4, protocol.RegEffect5, protocol.RegEffect6, protocol.RegEffect7:
+		ioIndex := evt.Register - protocol.RegEffect0
+		if evt.ValueCount == 0 {
+			// Select only (readback)
+		} else if params, err := effects.DecodeRecord(evt.Values[:evt.ValueCount]); err != nil {
+			println("Invalid effect: ", err.Error())
+		} else if err := params.Validate(ioIndex); err != nil {
+			println("Invalid effect: ", err.Error())
+		} else {
+			r.setEffect(ioIndex, params)
+		}
+	case protocol.RegPWMRelease:
+		if evt.HasValue {
+			for idx := uint8(0); idx < protocol.IOPinCount; idx++ {
+				if evt.Value&(1<<idx) != 0 {
+					r.releasePWM(idx)
+				}
+			}
+		}
+	case protocol.RegPWMConflicts:
+		if evt.HasValue {
+			r.pwm.conflicts &^= evt.Value
+		}
+	case protocol.RegCarSensorAckEdges:
+		if evt.HasValue {
+			r.sensorState.AckEdges(evt.Uint16())
+		}
+	case protocol.RegCarSensorResetPassCounts:
+		if evt.HasValue {
+			r.sensorState.ResetPassCounts(evt.Uint16())
+		}
+	case protocol.RegDetectionParams:
+		if evt.ValueCount == 1 {
+			// Select sensor
+			r.selectedDetectionSensor = evt.Value
+		} else if evt.ValueCount > 1 {
+			r.selectedDetectionSensor = evt.Value
+			update := r.config.Detection
+			if err := update.SetRecord(evt.Value, evt.Values[1:evt.ValueCount]); err != nil {
+				println("Invalid detection parameters: ", err.Error())
+			} else {
+				r.setDetection(update)
+			}
+		}
+	case protocol.RegCalibration:
+		if evt.ValueCount == 1 {
+			// Select sensor
+			r.selectedCalibrationSensor = evt.Value
+		} else if evt.ValueCount > 1 {
+			r.selectedCalibrationSensor = evt.Value
+			if c, err := config.DecodeCalibrationRecord(evt.Values[1:evt.ValueCount]); err != nil {
+				println("Invalid calibration: ", err.Error())
+			} else if evt.Value < protocol.MaxSensorCount {
+				update := r.config.Detection
+				update.Calibration[evt.Value] = c
+				r.setDetection(update)
+			}
+		}
+	case protocol.RegDetector:
+		if evt.ValueCount == 1 {
+			// Select sensor
+			r.selectedDetectorSensor = evt.Value
+		} else if evt.ValueCount > 1 {
+			r.selectedDetectorSensor = evt.Value
+			if params, err := config.DecodeDetectorRecord(evt.Values[1:evt.ValueCount]); err != nil {
+				println("Invalid detector: ", err.Error())
+			} else if err := params.Validate(); err != nil {
+				println("Invalid detector: ", err.Error())
+			} else if evt.Value < protocol.MaxSensorCount {
+				update := r.config.Detection
+				update.Detectors[evt.Value] = params
+				r.setDetection(update)
+			}
+		}
+	case protocol.RegPresenceMode:
+		if evt.ValueCount >= 2 {
+			update := r.config.Detection
+			update.Presence = evt.Uint16()
+			r.setDetection(update)
+		}
+	case protocol.RegCalibrate:
+		if evt.ValueCount >= 2 {
+			r.startCalibration(evt.Uint16())
+		}
+	case protocol.RegCalibrationMode:
+		if evt.HasValue {
+			update := r.config.Detection
+			update.CalibrateAtBoot = evt.Value&protocol.CalibrationModeAtBoot != 0
+			r.setDetection(update)
+		}
+	case protocol.RegProbeInterval:
+		if evt.ValueCount >= 2 {
+			update := r.config.Detection
+			update.ProbeInterval = time.Duration(evt.Uint16()) * time.Millisecond
+			if err := update.Validate(); err != nil {
+				println("Invalid probe interval: ", err.Error())
+			} else {
+				r.setDetection(update)
+			}
+		}
+	case protocol.RegConfigI2CAddress:
+		if evt.HasValue {
+			update := r.config
+			update.I2CAddress = evt.Value
+			r.updateConfig(update)
+		}
+	case protocol.RegConfigPinModes:
+		if evt.HasValue {
+			update := r.config
+			copy(update.PinModes[:], evt.Values[:evt.ValueCount])
+			r.updateConfig(update)
+		}
+	case protocol.RegConfigOutputDefaults:
+		if evt.HasValue {
+			update := r.config
+			update.OutputDefaults = evt.Value
+			copy(update.PCFOutputDefaults[:], evt.Values[1:evt.ValueCount])
+			r.updateConfig(update)
+		}
+	case protocol.RegConfigPWMDefaults:
+		if evt.HasValue {
+			update := r.config
+			copy(update.PWMDefaults[:], evt.Values[:evt.ValueCount])
+			r.updateConfig(update)
+		}
+	case protocol.RegConfigLabel:
+		if evt.HasValue {
+			update := r.config
+			update.Label = trimLabel(evt.Values[:evt.ValueCount])
+			r.updateConfig(update)
+		}
+	case protocol.RegSaveConfig:
+		if evt.HasValue && evt.Value == protocol.ConfigSaveMagic {
+			r.saveConfig()
+		}
+	case protocol.RegFactoryReset:
+		if evt.HasValue && evt.Value == protocol.FactoryResetMagic {
+			r.factoryReset()
+		}
+	case protocol.RegPulseConfig:
+		if evt.ValueCount == 1 {
+			// Select output
+			r.selectedPulseOutput = evt.Value
+		} else if evt.ValueCount > 1 {
+			r.selectedPulseOutput = evt.Value
+			if params, err := config.DecodePulseRecord(evt.Values[1:evt.ValueCount]); err != nil {
+				println("Invalid pulse parameters: ", err.Error())
+			} else if evt.Value < protocol.PulseOutputCount {
+				update := r.config
+				update.Pulses[evt.Value] = params
+				if r.updateConfig(update) {
+					r.configurePulses()
+				}
+			}
+		}
+	case protocol.RegPulseTripped:
+		for idx := 0; idx < evt.ValueCount && idx < len(r.outputGroups); idx++ {
+			r.outputGroups[idx].AckTripped(evt.Values[idx])
+		}
+	case protocol.RegFailsafeStatus:
+		if evt.HasValue {
+			r.failsafeFlags &^= protocol.FailsafeFlagTriggered
+		}
+	case protocol.RegFailsafeTimeout:
+		if evt.ValueCount >= 2 {
+			update := r.config
+			update.Failsafe.Timeout = time.Duration(evt.Uint16()) * time.Millisecond
+			r.updateConfig(update)
+		}
+	case protocol.RegFailsafeOutputs:
+		if evt.HasValue {
+			update := r.config
+			update.Failsafe.Outputs = evt.Value
+			copy(update.Failsafe.PCFOutputs[:], evt.Values[1:evt.ValueCount])
+			r.updateConfig(update)
+		}
+	case protocol.RegFailsafePWM:
+		if evt.HasValue {
+			update := r.config
+			copy(update.Failsafe.PWM[:], evt.Values[:evt.ValueCount])
+			r.updateConfig(update)
+		}
+	case protocol.RegBusStats:
+		if evt.HasValue {
+			r.selectedBusDevice = evt.Value
+		}
+	case protocol.RegBusResetStats:
+		if evt.HasValue {
+			r.bus.ResetStats()
+		}
+	case protocol.RegCarSensorState, protocol.RegCarSensorRisingEdges, protocol.RegCarSensorFallingEdges,
+		protocol.RegCarSensorEventStatus, protocol.RegCarSensorEvent, protocol.RegCarSensorPolarity, protocol.RegConfigStatus,
+		protocol.RegResetStatus, protocol.RegInputState, protocol.RegInputRisingEdges, protocol.RegInputFallingEdges,
+		protocol.RegPairResult0, protocol.RegPairResult1, protocol.RegPairResult2, protocol.RegPairResult3:
+		// Ignore
+	default:
+		println("I2C:Receive: Invalid register ", evt.Register, evt.HasValue, evt.Value)
+	}
+}
+
+// Handle a register read
+func (r *i2cRegisters) request(evt incomingI2CEvent) {
+	// Reply with current state of sensors
+	if r.lastRequestReq != evt.Register {
+		println("I2C:Request ", evt.Register)
+		r.lastRequestReq = evt.Register
+	}
+	switch evt.Register {
+	case protocol.RegVersionMajor:
+		r.i2c.Reply(version[0:1])
+	case protocol.RegVersionMinor:
+		r.i2c.Reply(version[1:2])
+	case protocol.RegVersionPatch:
+		r.i2c.Reply(version[2:3])
+	case protocol.RegCarSensorCount:
+		r.i2c.Reply([]byte{r.carSensorBitsCount})
+	case protocol.RegI2COutputCount:
+		r.i2c.Reply([]byte{r.i2cOutputBitsCount})
+	case protocol.RegOutput:
+		flags := uint8(0)
+		if r.outputsWritten {
+			flags |= protocol.OutputFlagWritten
+		}
+		r.i2c.Reply([]byte{r.appliedOutputs, flags})
+	case protocol.RegOutputI2C0, protocol.RegOutputI2C1, protocol.RegOutputI2C2, protocol.RegOutputI2C3, protocol.RegOutputI2C4, protocol.RegOutputI2C5, protocol.RegOutputI2C6, protocol.RegOutputI2C7:
+		r.i2c.Reply(r.pcfOutputStatus(evt.Register - protocol.RegOutputI2C0))
+	case protocol.RegConfigurePWM0, protocol.RegConfigurePWM1, protocol.RegConfigurePWM2, protocol.RegConfigurePWM3, protocol.RegConfigurePWM4, protocol.RegConfigurePWM5, protocol.RegConfigurePWM6, protocol.RegConfigurePWM7:
+		value := uint8(0)
+		if ioIndex := evt.Register - protocol.RegConfigurePWM0; r.isPWM[ioIndex] {
+			value = uint8(r.pwmDuty[ioIndex] >> 8)
+		}
+		r.i2c.Reply([]byte{value})
+	case protocol.RegCarSensorState:
+		// Reply & reset detections
+		r.replyUint16(r.sensorState.ReadLatch())
+	case protocol.RegCarSensorRisingEdges:
+		r.replyUint16(r.sensorState.RisingEdges())
+	case protocol.RegCarSensorFallingEdges:
+		r.replyUint16(r.sensorState.FallingEdges())
+	case protocol.RegCarSensorPolarity:
+		r.replyUint16(r.sensorState.Polarity())
+	case protocol.RegPairConfig0, protocol.RegPairConfig1, protocol.RegPairConfig2, protocol.RegPairConfig3:
+		r.i2c.Reply(r.config.Pairs[evt.Register-protocol.RegPairConfig0].EncodeRecord())
+	case protocol.RegPairResult0, protocol.RegPairResult1, protocol.RegPairResult2, protocol.RegPairResult3:
+		// Reply & mark measurement as read
+		r.i2c.Reply(r.pairs.ReadRecord(int(evt.Register - protocol.RegPairResult0)))
+	case protocol.RegPinMode0, protocol.RegPinMode1, protocol.RegPinMode2, protocol.RegPinMode3, protocol.RegPinMode4, protocol.RegPinMode5, protocol.RegPinMode6, protocol.RegPinMode7:
+		r.i2c.Reply([]byte{r.pinMode(evt.Register - protocol.RegPinMode0)})
+	case protocol.RegPinErrors:
+		r.i2c.Reply([]byte{r.pinErrors, r.lastPinError})
+	case protocol.RegInputState:
+		// Reply & reset latched inputs
+		r.i2c.Reply([]byte{uint8(r.inputState.ReadLatch())})
+	case protocol.RegInputRisingEdges:
+		r.i2c.Reply([]byte{uint8(r.inputState.RisingEdges())})
+	case protocol.RegInputFallingEdges:
+		r.i2c.Reply([]byte{uint8(r.inputState.FallingEdges())})
+	case protocol.RegInputConfig0, protocol.RegInputConfig1, protocol.RegInputConfig2, protocol.RegInputConfig3, protocol.RegInputConfig4, protocol.RegInputConfig5, protocol.RegInputConfig6, protocol.RegInputConfig7:
+		r.i2c.Reply(r.config.Inputs[evt.Register-protocol.RegInputConfig0].EncodeRecord())
+	case protocol.RegCarSensorEventStatus:
+		r.i2c.Reply(r.sensorState.Events().StatusRecord())
+	case protocol.RegCarSensorEvent:
+		// Reply & remove event from queue
+		r.i2c.Reply(r.sensorState.Events().PopRecord())
+	case protocol.RegDetectionParams:
+		r.i2c.Reply(r.config.Detection.Record(r.selectedDetectionSensor))
+	case protocol.RegProbeInterval:
+		r.replyUint16(uint16(r.config.Detection.ProbeInterval / time.Millisecond))
+	case protocol.RegCalibration:
+		c := config.SensorCalibration{}
+		if r.selectedCalibrationSensor < protocol.MaxSensorCount {
+			c = r.config.Detection.Calibration[r.selectedCalibrationSensor]
+		}
+		r.i2c.Reply(c.EncodeRecord())
+	case protocol.RegCalibrate:
+		r.replyUint16(r.calibrating)
+	case protocol.RegPresenceMode:
+		r.replyUint16(r.config.Detection.Presence)
+	case protocol.RegDetector:
+		params := config.DetectorParams{}
+		if r.selectedDetectorSensor < protocol.MaxSensorCount {
+			params = r.config.Detection.Detectors[r.selectedDetectorSensor]
+		}
+		r.i2c.Reply(params.EncodeRecord())
+	case protocol.RegCalibrationMode:
+		mode := uint8(0)
+		if r.config.Detection.CalibrateAtBoot {
+			mode |= protocol.CalibrationModeAtBoot
+		}
+		r.i2c.Reply([]byte{mode})
+	case protocol.RegConfigStatus:
+		r.i2c.Reply([]byte{r.configVersion, r.configFlags})
+	case protocol.RegConfigI2CAddress:
+		r.i2c.Reply([]byte{r.config.I2CAddress})
+	case protocol.RegConfigPinModes:
+		r.i2c.Reply(r.config.PinModes[:])
+	case protocol.RegConfigOutputDefaults:
+		r.i2c.Reply(append([]byte{r.config.OutputDefaults}, r.config.PCFOutputDefaults[:]...))
+	case protocol.RegConfigPWMDefaults:
+		r.i2c.Reply(r.config.PWMDefaults[:])
+	case protocol.RegConfigLabel:
+		var label [protocol.LabelMaxSize]byte
+		copy(label[:], r.config.Label)
+		r.i2c.Reply(label[:])
+	case protocol.RegPWMConflicts:
+		r.i2c.Reply([]byte{r.pwm.conflicts})
+	case protocol.RegEffect0, protocol.RegEffect1, protocol.RegEffect2, protocol.RegEffect3, protocol.RegEffect4, protocol.RegEffect5, protocol.RegEffect6, protocol.RegEffect7:
+		var params effects.Params
+		if e := r.effects[evt.Register-protocol.RegEffect0]; e != nil {
+			params = e.Params()
+		}
+		r.i2c.Reply(params.EncodeRecord())
+	case protocol.RegServoTarget0, protocol.RegServoTarget1, protocol.RegServoTarget2, protocol.RegServoTarget3, protocol.RegServoTarget4, protocol.RegServoTarget5, protocol.RegServoTarget6, protocol.RegServoTarget7:
+		r.i2c.Reply(r.servos[evt.Register-protocol.RegServoTarget0].StatusRecord())
+	case protocol.RegServoConfig0, protocol.RegServoConfig1, protocol.RegServoConfig2, protocol.RegServoConfig3, protocol.RegServoConfig4, protocol.RegServoConfig5, protocol.RegServoConfig6, protocol.RegServoConfig7:
+		r.i2c.Reply(r.config.Servos[evt.Register-protocol.RegServoConfig0].EncodeRecord())
+	case protocol.RegPWMRelease:
+		pwmPins := uint8(0)
+		for idx, isPWM := range r.isPWM {
+			if isPWM {
+				pwmPins |= 1 << idx
+			}
+		}
+		r.i2c.Reply([]byte{pwmPins})
+	case protocol.RegPWMDuty0, protocol.RegPWMDuty1, protocol.RegPWMDuty2, protocol.RegPWMDuty3, protocol.RegPWMDuty4, protocol.RegPWMDuty5, protocol.RegPWMDuty6, protocol.RegPWMDuty7:
+		r.replyUint16(r.pwmDuty[evt.Register-protocol.RegPWMDuty0])
+	case protocol.RegPWMFrequency0, protocol.RegPWMFrequency1, protocol.RegPWMFrequency2, protocol.RegPWMFrequency3, protocol.RegPWMFrequency4, protocol.RegPWMFrequency5, protocol.RegPWMFrequency6, protocol.RegPWMFrequency7:
+		r.replyUint16(r.pwmFrequency[evt.Register-protocol.RegPWMFrequency0])
+	case protocol.RegPulseConfig:
+		if r.selectedPulseOutput < protocol.PulseOutputCount {
+			r.i2c.Reply(r.config.Pulses[r.selectedPulseOutput].EncodeRecord())
+		} else {
+			r.i2c.Reply([]byte{0xff, 0xff})
+		}
+	case protocol.RegPulseTripped:
+		var tripped [len(r.outputGroups)]uint8
+		for idx := range r.outputGroups {
+			tripped[idx] = r.outputGroups[idx].Tripped()
+		}
+		r.i2c.Reply(tripped[:])
+	case protocol.RegFailsafeStatus:
+		r.i2c.Reply([]byte{r.failsafeFlags})
+	case protocol.RegResetStatus:
+		r.i2c.Reply(r.supervisor.resetStatus.Record())
+	case protocol.RegFailsafeTimeout:
+		r.replyUint16(uint16(r.config.Failsafe.Timeout / time.Millisecond))
+	case protocol.RegFailsafeOutputs:
+		r.i2c.Reply(append([]byte{r.config.Failsafe.Outputs}, r.config.Failsafe.PCFOutputs[:]...))
+	case protocol.RegFailsafePWM:
+		r.i2c.Reply(r.config.Failsafe.PWM[:])
+	case protocol.RegBusStats:
+		r.i2c.Reply(busStatsRecord(r.bus.DeviceStats(uint16(r.selectedBusDevice))))
+	default:
+		if evt.Register >= protocol.RegCarSensorPassCount0 && evt.Register <= protocol.RegCarSensorPassCount15 {
+			r.replyUint16(r.sensorState.PassCount(int(evt.Register - protocol.RegCarSensorPassCount0)))
+		} else {
+			r.i2c.Reply([]byte{0xff, 0xff})
+		}
+	}
+}
+
+// Encode the given statistics as bus statistics record
+func busStatsRecord(stats manager.DeviceStats) []byte {
+	return []byte{
+		uint8(stats.Address),
+		uint8(stats.Transactions), uint8(stats.Transactions >> 8), uint8(stats.Transactions >> 16), uint8(stats.Transactions >> 24),
+		uint8(stats.Errors), uint8(stats.Errors >> 8), uint8(stats.Errors >> 16), uint8(stats.Errors >> 24),
+	}
+}
+
+// Reply with a 16-bit value (LSB first)
+func (r *i2cRegisters) replyUint16(value uint16) {
+	r.i2c.Reply([]byte{uint8(value), uint8(value >> 8)})
+}
+
+// Set the on-pcb output pins (that are not in PWM mode)
+func (r *i2cRegisters) setOutputs(value uint8) {
+	if value&0x01 != 0 && r.io0Locked && r.pinMode(0) == protocol.PinModeOutput {
+		// Since we pull IO1 down to use alternate i2c address,
+		// we do not allow setting it high when using the alternate address.
+		r.rejectPin(0, protocol.PinErrorAddressStrap)
+	}
+	r.outputs = r.outputGroups[0].Set(value, millisSinceBoot())
+	r.applyOutputs()
+}
+
+// Drive the on-pcb output pins (that are not in PWM mode)
+func (r *i2cRegisters) applyOutputs() {
+	applied := uint8(0)
+	for idx, io := range IO {
+		if r.isPWM[idx] || r.isInput[idx] {
+			continue
+		}
+		bit := r.outputs&(1<<idx) != 0
+		switch r.digitalModes[idx] {
+		case protocol.PinModeDisabled:
+			io.Configure(machine.PinConfig{Mode: machine.PinInput})
+			bit = false
+		case protocol.PinModeOpenDrain:
+			setIOOpenDrain(io, bit)
+		default:
+			if idx == 0 && r.io0Locked {
+				// Never drive the address strap high
+				bit = false
+			}
+			setIOx(io, bit)
+		}
+		if bit {
+			applied |= 1 << idx
+		}
+	}
+	r.appliedOutputs = applied
+	r.outputsWritten = true
+}
+
+// Set the PWM duty cycle of an on-pcb pin, switching it to PWM mode
+func (r *i2cRegisters) setPWM(ioIndex uint8, duty uint16) {
+	if !r.isPWM[ioIndex] && !r.canDrive(ioIndex, true) {
+		return
+	}
+	if r.isPWM[ioIndex] && !r.isServo[ioIndex] && !r.isEffect[ioIndex] && r.pwmDuty[ioIndex] == duty {
+		// No changes
+		return
+	}
+	println("setPWM", ioIndex, " -> ", duty)
+	period := pwmslices.PeriodOfFrequency(r.pwmFrequency[ioIndex])
+	if err := r.pwm.set(ioIndex, duty, period); err != nil {
+		println("Failed to set PWM: ", ioIndex, err.Error())
+		return
+	}
+	r.isPWM[ioIndex] = true
+	r.isServo[ioIndex] = false
+	r.pwmDuty[ioIndex] = duty
+	r.clearEffect(ioIndex)
+}
+
+// Set the PWM frequency of an on-pcb pin.
+// If the pin is in PWM mode, the new frequency is applied immediately.
+func (r *i2cRegisters) setPWMFrequency(ioIndex uint8, hz uint16) {
+	if r.pwmFrequency[ioIndex] == hz {
+		// No changes
+		return
+	}
+	if r.isServo[ioIndex] {
+		println("Cannot change PWM frequency of servo: ", ioIndex)
+		return
+	}
+	println("setPWMFrequency", ioIndex, " -> ", hz)
+	if r.isPWM[ioIndex] {
+		if err := r.pwm.set(ioIndex, r.pwmDuty[ioIndex], pwmslices.PeriodOfFrequency(hz)); err != nil {
+			println("Failed to set PWM frequency: ", ioIndex, err.Error())
+			return
+		}
+	}
+	r.pwmFrequency[ioIndex] = hz
+}
+
+// Return an on-pcb pin from PWM mode to digital output
+func (r *i2cRegisters) releasePWM(ioIndex uint8) {
+	if !r.isPWM[ioIndex] {
+		return
+	}
+	println("releasePWM", ioIndex)
+	r.pwm.release(ioIndex)
+	r.isPWM[ioIndex] = false
+	r.isServo[ioIndex] = false
+	r.pwmDuty[ioIndex] = 0
+	r.clearEffect(ioIndex)
+	r.applyOutputs()
+}
+
+// Set the target position of the servo on an on-pcb pin,
+// switching it to servo mode
+func (r *i2cRegisters) setServoTarget(ioIndex, target uint8) {
+	if !r.isServo[ioIndex] && !r.canDrive(ioIndex, true) {
+		return
+	}
+	s := r.servos[ioIndex]
+	if !r.isServo[ioIndex] {
+		// Claim PWM channel at servo frequency
+		println("setServo", ioIndex)
+		pulse := s.Pulse()
+		if err := r.pwm.set(ioIndex, servo.Duty(pulse), pwmslices.PeriodOfFrequency(servo.Frequency)); err != nil {
+			println("Failed to set servo mode: ", ioIndex, err.Error())
+			return
+		}
+		r.isPWM[ioIndex] = true
+		r.isServo[ioIndex] = true
+		r.pwmFrequency[ioIndex] = servo.Frequency
+		r.clearEffect(ioIndex)
+	}
+	s.SetTarget(target, millisSinceBoot())
+	r.updateServo(ioIndex)
+}
+
+// Move all servos towards their targets
+func (r *i2cRegisters) updateServos() {
+	for idx, isServo := range r.isServo {
+		if isServo {
+			r.updateServo(uint8(idx))
+		}
+	}
+}
+
+// Move the servo on an on-pcb pin towards its target & update its pulses
+func (r *i2cRegisters) updateServo(ioIndex uint8) {
+	pulse, signal := r.servos[ioIndex].Update(millisSinceBoot())
+	duty := uint16(0)
+	if signal {
+		duty = servo.Duty(pulse)
+	}
+	if duty == r.pwmDuty[ioIndex] {
+		// No changes
+		return
+	}
+	if err := r.pwm.set(ioIndex, duty, pwmslices.PeriodOfFrequency(servo.Frequency)); err != nil {
+		println("Failed to update servo: ", ioIndex, err.Error())
+		return
+	}
+	r.pwmDuty[ioIndex] = duty
+}
+
+// Send a value to a PCF8574 output device
+func (r *i2cRegisters) sendPCFOutput(deviceIndex, value uint8) {
+	output := pcfOutput{
+		DeviceIndex: deviceIndex,
+		Value:       value,
+	}
+	select {
+	case r.outputStatus <- output:
+		// We're done
+		if int(deviceIndex) < len(r.pcfPending) {
+			r.pcfPending[deviceIndex]++
+		}
+	case <-time.After(time.Millisecond * 100):
+		// We did not send the bit in time
+		println("Failed to send PCF output in time: ", output.Value, "->", output.DeviceIndex)
+		if int(deviceIndex) < len(r.pcfFlags) {
+			r.pcfFlags[deviceIndex] |= protocol.OutputFlagFailed
+		}
+	}
+}
+
+// Update the detection configuration & pass it to the sensor loop
+func (r *i2cRegisters) setDetection(update config.Detection) {
+	r.config.Detection = update
+	r.configFlags |= protocol.ConfigFlagModified
+	publishDetection(update, r.detectionChanges)
+}
+
+// Save the configuration in flash
+func (r *i2cRegisters) saveConfig() {
+	if err := r.configStore.Save(r.config); err != nil {
+		println("Failed to save configuration: ", err.Error())
+	} else {
+		println("Saved configuration")
+		r.savedConfig = r.config
+		r.configVersion = config.CurrentVersion
+		r.configFlags = protocol.ConfigFlagLoaded
+	}
+}
+
+// Erase the configuration from flash and apply the factory configuration
+// the same way as at boot.
+func (r *i2cRegisters) factoryReset() {
+	if err := r.configStore.Erase(); err != nil {
+		println("Failed to erase configuration: ", err.Error())
+		return
+	}
+	println("Restored factory configuration")
+	r.config = config.Default()
+	r.savedConfig = r.config
+	r.configVersion = 0
+	r.configFlags = 0
+	// Stop PWM, servos & effects, so all pins start from digital output like at boot
+	for idx := range r.isPWM {
+		r.releasePWM(uint8(idx))
+	}
+	for idx, s := range r.servos {
+		s.Configure(r.config.Servos[idx])
+	}
+	r.configurePulses()
+	r.configurePairs()
+	r.applyPowerOnDefaults()
+	publishDetection(r.config.Detection, r.detectionChanges)
+}
+
+// Update the configuration if it is valid.
+// Returns true if the configuration was updated.
+func (r *i2cRegisters) updateConfig(update config.Config) bool {
+	if err := update.Validate(); err != nil {
+		println("Invalid configuration: ", err.Error())
+		return false
+	}
+	r.config = update
+	r.configFlags |= protocol.ConfigFlagModified
+	return true
+}
+
+// Send the given detection configuration to the sensor loop,
+// replacing any configuration it has not yet applied.
+func publishDetection(detectionConfig config.Detection, detectionChanges chan config.Detection) {
+	select {
+	case <-detectionChanges:
+		// Drop unapplied configuration
+	default:
+	}
+	detectionChanges <- detectionConfig
+}
+
+// Convert label bytes into a label, dropping trailing zeros
+func trimLabel(value []byte) string {
+	for len(value) > 0 && value[len(value)-1] == 0 {
+		value = value[:len(value)-1]
+	}
+	return string(value)
+}
@@ -0,0 +1,13 @@
+// Package i2cbus defines the minimal I2C bus interface used by the device
+// drivers and the host-side client.
+//
+// The interface is satisfied by *machine.I2C (TinyGo), by linux.Bus
+// (/dev/i2c-N on a Raspberry Pi) and by mockbus.Bus (tests).
+package i2cbus
+
+// Bus implements I2C transactions as a controller.
+type Bus interface {
+	// Tx performs a single I2C transaction with the device at the given address.
+	// It writes w (if not empty), then reads into r (if not empty).
+	Tx(addr uint16, w, r []byte) error
+}
@@ -0,0 +1,105 @@
+package linux
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// ioctl request & flags from linux/i2c-dev.h & linux/i2c.h
+	ioctlI2CRdWr = 0x0707
+	flagRead     = 0x0001
+)
+
+// i2c_msg from linux/i2c.h
+type i2cMsg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   uintptr
+}
+
+// i2c_rdwr_ioctl_data from linux/i2c-dev.h
+type i2cRdWrIoctlData struct {
+	msgs  uintptr
+	nmsgs uint32
+}
+
+// Bus implements access to a Linux I2C bus device.
+type Bus struct {
+	mutex sync.Mutex
+	f     *os.File
+}
+
+// Open the I2C bus with given number (/dev/i2c-<busNumber>).
+func Open(busNumber int) (*Bus, error) {
+	return OpenPath(fmt.Sprintf("/dev/i2c-%d", busNumber))
+}
+
+// OpenPath opens the I2C bus device at the given path.
+func OpenPath(path string) (*Bus, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open I2C bus: %w", err)
+	}
+	return &Bus{f: f}, nil
+}
+
+// Close the bus device.
+func (bus *Bus) Close() error {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	return bus.f.Close()
+}
+
+// Tx performs a single I2C transaction with the device at the given address.
+// It writes w (if not empty), then reads into r (if not empty) using
+// a repeated start condition.
+func (bus *Bus) Tx(addr uint16, w, r []byte) error {
+	if len(w) == 0 && len(r) == 0 {
+		return nil
+	}
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	// The kernel receives the buffers as plain addresses, so all of them
+	// are heap allocated & pinned until the ioctl has returned.
+	var pinner runtime.Pinner
+	defer pinner.Unpin()
+	msgs := new([2]i2cMsg)
+	data := new(i2cRdWrIoctlData)
+	pinner.Pin(msgs)
+	pinner.Pin(data)
+	count := 0
+	if len(w) > 0 {
+		pinner.Pin(&w[0])
+		msgs[count] = i2cMsg{
+			addr: addr,
+			len:  uint16(len(w)),
+			buf:  uintptr(unsafe.Pointer(&w[0])),
+		}
+		count++
+	}
+	if len(r) > 0 {
+		pinner.Pin(&r[0])
+		msgs[count] = i2cMsg{
+			addr:  addr,
+			flags: flagRead,
+			len:   uint16(len(r)),
+			buf:   uintptr(unsafe.Pointer(&r[0])),
+		}
+		count++
+	}
+	data.msgs = uintptr(unsafe.Pointer(&msgs[0]))
+	data.nmsgs = uint32(count)
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, bus.f.Fd(), ioctlI2CRdWr, uintptr(unsafe.Pointer(data)))
+	if errno != 0 {
+		return fmt.Errorf("I2C transaction with 0x%02x failed: %w", addr, errno)
+	}
+	return nil
+}
@@ -0,0 +1,4 @@
+// Package linux implements an I2C bus on top of the Linux i2c-dev
+// interface (/dev/i2c-N), e.g. the I2C bus of a Raspberry Pi attached through
+// an RPiConnector.
+package linux
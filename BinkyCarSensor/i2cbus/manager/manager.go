@@ -0,0 +1,168 @@
+// Package manager implements an I2C bus manager that owns a single bus,
+// serializes the transactions of multiple goroutines and keeps
+// per-device transaction statistics.
+//
+// Transactions issued through a high priority client are always
+// handled before pending transactions of low priority clients,
+// so output changes are not delayed behind sensor polls.
+//
+//	m := manager.New(machine.I2C0)
+//	go m.Run()
+//	outputBus := m.Client(manager.PriorityHigh)
+//	sensorBus := m.Client(manager.PriorityLow)
+package manager
+
+import (
+	"sync"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus"
+)
+
+// Priority of the transactions of a client.
+type Priority uint8
+
+const (
+	// PriorityLow is used for background work such as sensor polls.
+	PriorityLow Priority = iota
+	// PriorityHigh is used for latency sensitive work such as output writes.
+	PriorityHigh
+)
+
+// DeviceStats holds the transaction statistics of a single device.
+type DeviceStats struct {
+	// I2C address of the device
+	Address uint16
+	// Number of transactions (including failed ones)
+	Transactions uint32
+	// Number of failed transactions
+	Errors uint32
+	// Error of the last failed transaction
+	LastError error
+}
+
+// Manager owns an I2C bus and serializes all transactions on it.
+type Manager struct {
+	bus  i2cbus.Bus
+	high chan *request
+	low  chan *request
+
+	mutex sync.Mutex
+	stats []DeviceStats
+}
+
+// A single pending transaction
+type request struct {
+	addr uint16
+	w, r []byte
+	done chan error
+}
+
+// New initializes a manager for the given bus.
+// Run must be called (in a separate goroutine) before clients are used.
+func New(bus i2cbus.Bus) *Manager {
+	return &Manager{
+		bus:  bus,
+		high: make(chan *request),
+		low:  make(chan *request),
+	}
+}
+
+// Run handles transactions until the program ends.
+func (m *Manager) Run() {
+	for {
+		var req *request
+		select {
+		case req = <-m.high:
+		default:
+			select {
+			case req = <-m.high:
+			case req = <-m.low:
+			}
+		}
+		err := m.bus.Tx(req.addr, req.w, req.r)
+		m.record(req.addr, err)
+		req.done <- err
+	}
+}
+
+// Client returns a bus that issues its transactions through the manager
+// with given priority.
+// A client must not be used by multiple goroutines at the same time;
+// create a client per goroutine instead.
+func (m *Manager) Client(priority Priority) *Client {
+	c := &Client{
+		req: request{done: make(chan error, 1)},
+	}
+	if priority == PriorityHigh {
+		c.queue = m.high
+	} else {
+		c.queue = m.low
+	}
+	return c
+}
+
+// Stats returns the statistics of all devices that were addressed so far,
+// in the order in which they were first addressed.
+func (m *Manager) Stats() []DeviceStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]DeviceStats(nil), m.stats...)
+}
+
+// DeviceStats returns the statistics of the device at the given address.
+func (m *Manager) DeviceStats(addr uint16) DeviceStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, s := range m.stats {
+		if s.Address == addr {
+			return s
+		}
+	}
+	return DeviceStats{Address: addr}
+}
+
+// ResetStats clears the statistics of all devices.
+func (m *Manager) ResetStats() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.stats = nil
+}
+
+// Update the statistics of the device at given address
+func (m *Manager) record(addr uint16, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	idx := -1
+	for i, s := range m.stats {
+		if s.Address == addr {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		m.stats = append(m.stats, DeviceStats{Address: addr})
+		idx = len(m.stats) - 1
+	}
+	s := &m.stats[idx]
+	s.Transactions++
+	if err != nil {
+		s.Errors++
+		s.LastError = err
+	}
+}
+
+// Client is a bus that issues its transactions through a manager.
+type Client struct {
+	queue chan<- *request
+	req   request
+}
+
+// Tx performs a single I2C transaction with the device at the given address.
+// It blocks until the manager has handled the transaction.
+func (c *Client) Tx(addr uint16, w, r []byte) error {
+	c.req.addr = addr
+	c.req.w = w
+	c.req.r = r
+	c.queue <- &c.req
+	return <-c.req.done
+}
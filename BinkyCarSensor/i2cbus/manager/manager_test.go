@@ -0,0 +1,153 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus/mockbus"
+)
+
+// Device that blocks its first transaction until released
+type blockingDevice struct {
+	entered chan struct{}
+	release chan struct{}
+	blocked bool
+}
+
+func newBlockingDevice() *blockingDevice {
+	return &blockingDevice{
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (d *blockingDevice) Tx(w, r []byte) error {
+	if !d.blocked {
+		d.blocked = true
+		close(d.entered)
+		<-d.release
+	}
+	return nil
+}
+
+// Create a running manager on a mock bus
+func newTestManager() (*Manager, *mockbus.Bus) {
+	bus := mockbus.New()
+	m := New(bus)
+	go m.Run()
+	return m, bus
+}
+
+func TestClientTx(t *testing.T) {
+	m, bus := newTestManager()
+	dev := &mockbus.OutputDevice{}
+	bus.Attach(0x20, dev)
+	c := m.Client(PriorityLow)
+
+	if err := c.Tx(0x20, []byte{0x5a}, nil); err != nil {
+		t.Fatalf("write failed: %s", err)
+	}
+	r := make([]byte, 2)
+	if err := c.Tx(0x20, nil, r); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	if dev.Value() != 0x5a || !bytes.Equal(r, []byte{0x5a, 0x5a}) {
+		t.Errorf("expected value 0x5a, got 0x%02x (read %v)", dev.Value(), r)
+	}
+	if err := c.Tx(0x21, []byte{1}, nil); !errors.Is(err, mockbus.ErrNACK) {
+		t.Errorf("expected ErrNACK from missing device, got %v", err)
+	}
+}
+
+func TestHighPriorityPreemption(t *testing.T) {
+	m, bus := newTestManager()
+	blocker := newBlockingDevice()
+	bus.Attach(0x10, blocker)
+	bus.Attach(0x20, &mockbus.OutputDevice{})
+	bus.Attach(0x21, &mockbus.OutputDevice{})
+
+	// Keep the manager busy, so the following transactions queue up
+	busy := make(chan error, 1)
+	go func() { busy <- m.Client(PriorityLow).Tx(0x10, []byte{1}, nil) }()
+	<-blocker.entered
+
+	done := make(chan error, 2)
+	go func() { done <- m.Client(PriorityLow).Tx(0x20, []byte{1}, nil) }()
+	go func() { done <- m.Client(PriorityHigh).Tx(0x21, []byte{1}, nil) }()
+	// Give both clients time to queue their transaction
+	time.Sleep(time.Millisecond * 50)
+	close(blocker.release)
+
+	for _, ch := range []chan error{busy, done, done} {
+		if err := <-ch; err != nil {
+			t.Fatalf("transaction failed: %s", err)
+		}
+	}
+	txs := bus.Transactions()
+	if len(txs) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(txs))
+	}
+	order := []uint16{txs[0].Address, txs[1].Address, txs[2].Address}
+	if order[0] != 0x10 || order[1] != 0x21 || order[2] != 0x20 {
+		t.Errorf("expected high priority transaction before pending low priority one, got order %#x", order)
+	}
+}
+
+func TestStats(t *testing.T) {
+	m, bus := newTestManager()
+	bus.Attach(0x20, &mockbus.OutputDevice{})
+	bus.Attach(0x48, &mockbus.OutputDevice{})
+	errCustom := errors.New("custom")
+	bus.FailNext(0x48, errCustom)
+	c := m.Client(PriorityLow)
+
+	c.Tx(0x48, []byte{1}, nil) // Fails with custom error
+	c.Tx(0x20, []byte{1}, nil)
+	c.Tx(0x48, []byte{1}, nil)
+	c.Tx(0x21, []byte{1}, nil) // No device
+	c.Tx(0x20, []byte{1}, nil)
+
+	expected := []struct {
+		address      uint16
+		transactions uint32
+		errors       uint32
+		lastError    error
+	}{
+		{0x48, 2, 1, errCustom},
+		{0x20, 2, 0, nil},
+		{0x21, 1, 1, mockbus.ErrNACK},
+	}
+	stats := m.Stats()
+	if len(stats) != len(expected) {
+		t.Fatalf("expected stats of %d devices, got %d", len(expected), len(stats))
+	}
+	for idx, e := range expected {
+		s := stats[idx]
+		if s.Address != e.address || s.Transactions != e.transactions || s.Errors != e.errors || !errors.Is(s.LastError, e.lastError) {
+			t.Errorf("device %d: expected %+v, got %+v", idx, e, s)
+		}
+		if ds := m.DeviceStats(e.address); ds != s {
+			t.Errorf("device 0x%02x: expected DeviceStats %+v, got %+v", e.address, s, ds)
+		}
+	}
+	if s := m.DeviceStats(0x30); s != (DeviceStats{Address: 0x30}) {
+		t.Errorf("expected empty stats of unused device, got %+v", s)
+	}
+
+	// Stats returns a copy
+	stats[0].Transactions = 100
+	if m.DeviceStats(0x48).Transactions != 2 {
+		t.Error("Stats must return a copy")
+	}
+
+	m.ResetStats()
+	if len(m.Stats()) != 0 {
+		t.Errorf("expected no stats after reset, got %+v", m.Stats())
+	}
+	c.Tx(0x20, []byte{1}, nil)
+	if s := m.DeviceStats(0x20); s.Transactions != 1 || s.Errors != 0 {
+		t.Errorf("expected 1 transaction after reset, got %+v", s)
+	}
+}
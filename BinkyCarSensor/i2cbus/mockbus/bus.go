@@ -0,0 +1,113 @@
+// Package mockbus implements an in-memory I2C bus with simulated devices
+// attached to it, for use in tests.
+package mockbus
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrNACK is returned when a transaction is not acknowledged,
+	// e.g. because there is no device at the address.
+	ErrNACK = errors.New("i2c: no acknowledge")
+)
+
+// Device is implemented by simulated devices attached to the bus.
+type Device interface {
+	// Tx handles a single I2C transaction targeting this device.
+	Tx(w, r []byte) error
+}
+
+// Transaction records a single transaction on the bus.
+type Transaction struct {
+	Address uint16
+	Write   []byte
+	Read    []byte
+	Err     error
+}
+
+// Bus is an in-memory I2C bus.
+type Bus struct {
+	mutex        sync.Mutex
+	devices      map[uint16]Device
+	failures     map[uint16][]error
+	transactions []Transaction
+}
+
+// New initializes a new bus without devices.
+func New() *Bus {
+	return &Bus{
+		devices:  make(map[uint16]Device),
+		failures: make(map[uint16][]error),
+	}
+}
+
+// Attach the given device to the bus at the given address.
+func (bus *Bus) Attach(addr uint16, dev Device) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.devices[addr] = dev
+}
+
+// Detach the device at the given address from the bus.
+func (bus *Bus) Detach(addr uint16) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	delete(bus.devices, addr)
+}
+
+// FailNext causes the next transaction with the given address to fail
+// with the given error (ErrNACK if nil), without reaching the device.
+// Multiple calls queue multiple failures.
+func (bus *Bus) FailNext(addr uint16, err error) {
+	if err == nil {
+		err = ErrNACK
+	}
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.failures[addr] = append(bus.failures[addr], err)
+}
+
+// Transactions returns a copy of all transactions performed so far.
+func (bus *Bus) Transactions() []Transaction {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	return append([]Transaction(nil), bus.transactions...)
+}
+
+// Reset the recorded transactions.
+func (bus *Bus) ResetTransactions() {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.transactions = nil
+}
+
+// Tx performs a single I2C transaction with the device at the given address.
+func (bus *Bus) Tx(addr uint16, w, r []byte) error {
+	bus.mutex.Lock()
+	dev, found := bus.devices[addr]
+	var err error
+	if failures := bus.failures[addr]; len(failures) > 0 {
+		err = failures[0]
+		bus.failures[addr] = failures[1:]
+	} else if !found {
+		err = fmt.Errorf("%w from 0x%02x", ErrNACK, addr)
+	}
+	bus.mutex.Unlock()
+
+	if err == nil {
+		err = dev.Tx(w, r)
+	}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.transactions = append(bus.transactions, Transaction{
+		Address: addr,
+		Write:   append([]byte(nil), w...),
+		Read:    append([]byte(nil), r...),
+		Err:     err,
+	})
+	return err
+}
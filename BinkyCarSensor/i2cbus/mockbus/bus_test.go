@@ -0,0 +1,92 @@
+package mockbus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestTxMissingDevice(t *testing.T) {
+	bus := New()
+	if err := bus.Tx(0x20, []byte{1}, nil); !errors.Is(err, ErrNACK) {
+		t.Fatalf("expected ErrNACK, got %v", err)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	bus := New()
+	dev := &OutputDevice{}
+	bus.Attach(0x20, dev)
+	errCustom := errors.New("custom")
+	bus.FailNext(0x20, nil)
+	bus.FailNext(0x20, errCustom)
+
+	if err := bus.Tx(0x20, []byte{1}, nil); err != ErrNACK {
+		t.Errorf("expected ErrNACK on first transaction, got %v", err)
+	}
+	if err := bus.Tx(0x20, []byte{2}, nil); err != errCustom {
+		t.Errorf("expected custom error on second transaction, got %v", err)
+	}
+	if dev.Writes() != 0 {
+		t.Errorf("failed transactions must not reach the device, got %d writes", dev.Writes())
+	}
+	if err := bus.Tx(0x20, []byte{3}, nil); err != nil {
+		t.Errorf("expected third transaction to succeed, got %v", err)
+	}
+	if dev.Value() != 3 || dev.Writes() != 1 {
+		t.Errorf("expected value 3 after 1 write, got %d after %d writes", dev.Value(), dev.Writes())
+	}
+}
+
+func TestFailNextOtherAddress(t *testing.T) {
+	bus := New()
+	bus.Attach(0x20, &OutputDevice{})
+	bus.Attach(0x21, &OutputDevice{})
+	bus.FailNext(0x21, nil)
+
+	if err := bus.Tx(0x20, []byte{1}, nil); err != nil {
+		t.Errorf("expected transaction with 0x20 to succeed, got %v", err)
+	}
+	if err := bus.Tx(0x21, []byte{1}, nil); !errors.Is(err, ErrNACK) {
+		t.Errorf("expected ErrNACK from 0x21, got %v", err)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	bus := New()
+	bus.Attach(0x20, &OutputDevice{})
+	bus.FailNext(0x20, nil)
+
+	w := []byte{0x55}
+	r := make([]byte, 2)
+	bus.Tx(0x20, w, nil)
+	bus.Tx(0x20, w, r)
+	bus.Tx(0x21, nil, r)
+	w[0] = 0xaa
+	r[0] = 0
+
+	txs := bus.Transactions()
+	if len(txs) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(txs))
+	}
+	if txs[0].Address != 0x20 || !bytes.Equal(txs[0].Write, []byte{0x55}) || txs[0].Err != ErrNACK {
+		t.Errorf("unexpected first transaction %+v", txs[0])
+	}
+	if !bytes.Equal(txs[1].Write, []byte{0x55}) || !bytes.Equal(txs[1].Read, []byte{0x55, 0x55}) || txs[1].Err != nil {
+		t.Errorf("unexpected second transaction %+v", txs[1])
+	}
+	if txs[2].Address != 0x21 || len(txs[2].Write) != 0 || !errors.Is(txs[2].Err, ErrNACK) {
+		t.Errorf("unexpected third transaction %+v", txs[2])
+	}
+
+	// The returned slice is a copy
+	txs[0].Address = 0x30
+	if bus.Transactions()[0].Address != 0x20 {
+		t.Error("Transactions must return a copy")
+	}
+
+	bus.ResetTransactions()
+	if len(bus.Transactions()) != 0 {
+		t.Error("expected no transactions after reset")
+	}
+}
@@ -0,0 +1,39 @@
+package mockbus
+
+import "sync"
+
+// OutputDevice simulates a simple output device (like a PCF8574) that
+// stores the last byte written to it and returns it on read.
+type OutputDevice struct {
+	mutex  sync.Mutex
+	value  uint8
+	writes int
+}
+
+// Value returns the last byte written to the device.
+func (dev *OutputDevice) Value() uint8 {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	return dev.value
+}
+
+// Writes returns the number of writes to the device.
+func (dev *OutputDevice) Writes() int {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	return dev.writes
+}
+
+// Tx handles a single I2C transaction targeting this device.
+func (dev *OutputDevice) Tx(w, r []byte) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	if len(w) > 0 {
+		dev.value = w[len(w)-1]
+		dev.writes++
+	}
+	for i := range r {
+		r[i] = dev.value
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Package inputs implements debouncing of a group of 8 digital inputs
+// (the on-pcb IO pins).
+//
+// A change of the level of an input is only reported once the input
+// has been stable at its new level for its debounce time.
+package inputs
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Group tracks the debounced state of 8 inputs.
+type Group struct {
+	params       [8]config.InputParams
+	state        uint8     // Debounced state (bit set when input is active)
+	pending      uint8     // Inputs whose raw state differs from the debounced state
+	pendingSince [8]uint32 // Time (ms) the raw state of each pending input changed
+}
+
+// Configure the input settings of the input with given index (0..7).
+// The debounced state of the input is reset to inactive.
+func (g *Group) Configure(bit uint8, params config.InputParams) {
+	mask := uint8(1) << bit
+	g.params[bit] = params
+	g.state &^= mask
+	g.pending &^= mask
+}
+
+// Active returns true if the given level of the input with given index
+// (0..7) means the input is active.
+func (g *Group) Active(bit uint8, level bool) bool {
+	if g.params[bit].Mode == protocol.InputModePullUp {
+		return !level
+	}
+	return level
+}
+
+// Update the group with the raw state of all inputs (bit set when the
+// input is active), measured at the given time (ms).
+// Inputs that are not configured are ignored.
+// Returns the debounced state and true if it changed.
+func (g *Group) Update(raw uint8, now uint32) (uint8, bool) {
+	changed := false
+	for bit := uint8(0); bit < 8; bit++ {
+		mask := uint8(1) << bit
+		p := g.params[bit]
+		if !p.IsInput() || (raw^g.state)&mask == 0 {
+			g.pending &^= mask
+			continue
+		}
+		if g.pending&mask == 0 {
+			g.pending |= mask
+			g.pendingSince[bit] = now
+		}
+		if now-g.pendingSince[bit] >= uint32(p.Debounce) {
+			g.state ^= mask
+			g.pending &^= mask
+			changed = true
+		}
+	}
+	return g.state, changed
+}
+
+// State returns the debounced state of all inputs.
+func (g *Group) State() uint8 {
+	return g.state
+}
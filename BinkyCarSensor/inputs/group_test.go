@@ -0,0 +1,71 @@
+package inputs
+
+import (
+	"testing"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+func TestDebounce(t *testing.T) {
+	g := &Group{}
+	g.Configure(0, config.InputParams{Mode: protocol.InputModePullUp, Debounce: 20})
+	g.Configure(1, config.InputParams{Mode: protocol.InputModePullDown})
+	// Bit 2 is not an input
+
+	steps := []struct {
+		name    string
+		now     uint32
+		raw     uint8
+		state   uint8
+		changed bool
+	}{
+		{"without debounce time", 1000, 0x07, 0x02, true},
+		{"bouncing", 1010, 0x07, 0x02, false},
+		{"bounced back", 1015, 0x06, 0x02, false},
+		{"active again", 1016, 0x07, 0x02, false},
+		{"just before debounce time", 1035, 0x07, 0x02, false},
+		{"stable for debounce time", 1036, 0x07, 0x03, true},
+		{"stable", 1100, 0x07, 0x03, false},
+		{"inactive", 1200, 0x00, 0x01, true},
+		{"inactive for debounce time", 1220, 0x00, 0x00, true},
+		{"across timer wrap (pending)", 0xfffffff6, 0x01, 0x00, false},
+		{"across timer wrap (stable)", 0x0000000a, 0x01, 0x01, true},
+	}
+	for _, s := range steps {
+		state, changed := g.Update(s.raw, s.now)
+		if state != s.state || g.State() != s.state || changed != s.changed {
+			t.Errorf("%s: expected state 0x%02x (changed=%v), got 0x%02x (changed=%v)", s.name, s.state, s.changed, state, changed)
+		}
+	}
+
+	// Reconfiguring an input resets its state
+	g.Configure(0, config.InputParams{Mode: protocol.InputModePullUp, Debounce: 50})
+	if g.State() != 0 {
+		t.Errorf("expected state 0 after reconfigure, got 0x%02x", g.State())
+	}
+	if state, changed := g.Update(0x01, 0x00000014); state != 0 || changed {
+		t.Errorf("expected new debounce time to apply, got state 0x%02x (changed=%v)", state, changed)
+	}
+}
+
+func TestActive(t *testing.T) {
+	g := &Group{}
+	g.Configure(0, config.InputParams{Mode: protocol.InputModePullUp})
+	g.Configure(1, config.InputParams{Mode: protocol.InputModePullDown})
+	tests := []struct {
+		bit      uint8
+		level    bool
+		expected bool
+	}{
+		{0, false, true},
+		{0, true, false},
+		{1, false, false},
+		{1, true, true},
+	}
+	for _, test := range tests {
+		if active := g.Active(test.bit, test.level); active != test.expected {
+			t.Errorf("bit %d at level %v: expected active=%v, got %v", test.bit, test.level, test.expected, active)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/effects"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pwmslices"
+)
+
+// Start a light effect on an on-pcb pin.
+// EffectNone returns the pin to digital output.
+func (r *i2cRegisters) setEffect(ioIndex uint8, params effects.Params) {
+	if params.Kind == protocol.EffectNone {
+		r.releasePWM(ioIndex)
+		return
+	}
+	println("setEffect", ioIndex, " -> ", params.Kind)
+	if !r.claimEffectPin(ioIndex) {
+		return
+	}
+	if params.Kind == protocol.EffectAlternate {
+		if !r.claimEffectPin(params.Partner) {
+			r.releasePWM(ioIndex)
+			return
+		}
+	}
+	r.effects[ioIndex] = effects.New(params, millisSinceBoot(), uint32(ioIndex)+1)
+	r.updateEffect(ioIndex)
+}
+
+// Switch an on-pcb pin to PWM mode for use by an effect.
+// Returns true on success.
+func (r *i2cRegisters) claimEffectPin(ioIndex uint8) bool {
+	if !r.isPWM[ioIndex] && !r.canDrive(ioIndex, true) {
+		return false
+	}
+	r.clearEffect(ioIndex)
+	period := pwmslices.PeriodOfFrequency(r.pwmFrequency[ioIndex])
+	if err := r.pwm.set(ioIndex, 0, period); err != nil {
+		println("Failed to set effect: ", ioIndex, err.Error())
+		return false
+	}
+	r.isPWM[ioIndex] = true
+	r.isServo[ioIndex] = false
+	r.isEffect[ioIndex] = true
+	r.pwmDuty[ioIndex] = 0
+	return true
+}
+
+// Stop the light effect of an on-pcb pin (if any).
+// The partner of an alternating effect returns to digital output.
+func (r *i2cRegisters) clearEffect(ioIndex uint8) {
+	e := r.effects[ioIndex]
+	r.isEffect[ioIndex] = false
+	r.effects[ioIndex] = nil
+	if e != nil && e.Params().Kind == protocol.EffectAlternate {
+		partner := e.Params().Partner
+		if r.isEffect[partner] && r.effects[partner] == nil {
+			r.releasePWM(partner)
+		}
+	}
+}
+
+// Update the outputs of all light effects
+func (r *i2cRegisters) updateEffects() {
+	for idx, e := range r.effects {
+		if e != nil {
+			r.updateEffect(uint8(idx))
+		}
+	}
+}
+
+// Update the output(s) of the light effect of an on-pcb pin
+func (r *i2cRegisters) updateEffect(ioIndex uint8) {
+	e := r.effects[ioIndex]
+	now := millisSinceBoot()
+	r.setEffectLevel(ioIndex, e.Level(now))
+	if partner := e.Params().Partner; e.Params().Kind == protocol.EffectAlternate && r.isEffect[partner] && r.effects[partner] == nil {
+		r.setEffectLevel(partner, e.PartnerLevel(now))
+	}
+}
+
+// Set the PWM duty cycle of an on-pcb pin to the given (gamma-corrected) brightness level
+func (r *i2cRegisters) setEffectLevel(ioIndex, level uint8) {
+	duty := effects.Duty(level)
+	if duty == r.pwmDuty[ioIndex] {
+		// No changes
+		return
+	}
+	if err := r.pwm.set(ioIndex, duty, pwmslices.PeriodOfFrequency(r.pwmFrequency[ioIndex])); err != nil {
+		println("Failed to update effect: ", ioIndex, err.Error())
+		return
+	}
+	r.pwmDuty[ioIndex] = duty
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"machine"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Apply the input settings of an on-pcb pin.
+// InputModeNone returns the pin to digital output.
+func (r *i2cRegisters) setInput(ioIndex uint8, params config.InputParams) {
+	println("setInput", ioIndex, " -> ", params.Mode)
+	r.releasePWM(ioIndex)
+	r.inputs.Configure(ioIndex, params)
+	r.isInput[ioIndex] = params.IsInput()
+	switch params.Mode {
+	case protocol.InputModePullUp:
+		IO[ioIndex].Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	case protocol.InputModePullDown:
+		IO[ioIndex].Configure(machine.PinConfig{Mode: machine.PinInputPulldown})
+	default:
+		r.applyOutputs()
+	}
+	r.updateInputs()
+}
+
+// Apply the input settings of all on-pcb pins from the configuration
+func (r *i2cRegisters) configureInputs() {
+	for idx, params := range r.config.Inputs {
+		if params.IsInput() || r.isInput[idx] {
+			r.setInput(uint8(idx), params)
+		}
+	}
+}
+
+// Sample & debounce all inputs
+func (r *i2cRegisters) updateInputs() {
+	raw := uint8(0)
+	for idx, io := range IO {
+		if r.isInput[idx] && r.inputs.Active(uint8(idx), io.Get()) {
+			raw |= 1 << idx
+		}
+	}
+	now := millisSinceBoot()
+	state, _ := r.inputs.Update(raw, now)
+	r.inputState.Update(uint16(state), now)
+}
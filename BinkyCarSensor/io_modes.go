@@ -0,0 +1,101 @@
+package main
+
+import (
+	"machine"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pwmslices"
+)
+
+// Returns the current mode (protocol.PinModeXyz) of an on-pcb pin
+func (r *i2cRegisters) pinMode(ioIndex uint8) uint8 {
+	switch {
+	case r.isInput[ioIndex]:
+		return protocol.PinModeInput
+	case r.isServo[ioIndex]:
+		return protocol.PinModeServo
+	case r.isPWM[ioIndex]:
+		return protocol.PinModePWM
+	default:
+		return r.digitalModes[ioIndex]
+	}
+}
+
+// Switch an on-pcb pin to the given mode (protocol.PinModeXyz).
+// PWM & servo pins start at their power-on PWM value (or servo position),
+// inputs use their input settings (pull-up if none).
+func (r *i2cRegisters) setPinMode(ioIndex, mode uint8) {
+	if !protocol.IsValidPinMode(mode) {
+		r.rejectPin(ioIndex, protocol.PinErrorInvalidMode)
+		return
+	}
+	if mode == r.pinMode(ioIndex) {
+		// No changes
+		return
+	}
+	println("setPinMode", ioIndex, " -> ", mode)
+	switch mode {
+	case protocol.PinModeOutput, protocol.PinModeOpenDrain, protocol.PinModeDisabled:
+		r.digitalModes[ioIndex] = mode
+		if r.isInput[ioIndex] {
+			r.setInput(ioIndex, config.InputParams{})
+		} else if r.isPWM[ioIndex] {
+			r.releasePWM(ioIndex)
+		} else {
+			r.applyOutputs()
+		}
+	case protocol.PinModeInput:
+		params := r.config.Inputs[ioIndex]
+		if !params.IsInput() {
+			params.Mode = protocol.InputModePullUp
+		}
+		r.setInput(ioIndex, params)
+	case protocol.PinModePWM, protocol.PinModeServo:
+		if ioIndex == 0 && r.io0Locked {
+			r.rejectPin(ioIndex, protocol.PinErrorAddressStrap)
+			return
+		}
+		// Explicit mode change, so the pin may be driven again
+		r.digitalModes[ioIndex] = protocol.PinModeOutput
+		if r.isInput[ioIndex] {
+			r.setInput(ioIndex, config.InputParams{})
+		}
+		if mode == protocol.PinModeServo {
+			r.setServoTarget(ioIndex, r.config.PWMDefaults[ioIndex])
+		} else {
+			r.setPWM(ioIndex, pwmslices.DutyOfByte(r.config.PWMDefaults[ioIndex]))
+		}
+	}
+}
+
+// Returns true if an on-pcb pin may be driven (in PWM mode if pwm is set).
+// Otherwise the request is reported as rejected.
+func (r *i2cRegisters) canDrive(ioIndex uint8, pwm bool) bool {
+	if r.isInput[ioIndex] || (!r.isPWM[ioIndex] && r.digitalModes[ioIndex] == protocol.PinModeDisabled) {
+		r.rejectPin(ioIndex, protocol.PinErrorNotOutput)
+		return false
+	}
+	if pwm && ioIndex == 0 && r.io0Locked {
+		r.rejectPin(ioIndex, protocol.PinErrorAddressStrap)
+		return false
+	}
+	return true
+}
+
+// Record a rejected request for an on-pcb pin
+func (r *i2cRegisters) rejectPin(ioIndex, reason uint8) {
+	println("Rejected request for pin: ", ioIndex, " reason: ", reason)
+	r.pinErrors |= 1 << ioIndex
+	r.lastPinError = reason
+}
+
+// Drive an on-pcb pin in open-drain mode
+func setIOOpenDrain(io machine.Pin, value bool) {
+	if value {
+		io.Configure(machine.PinConfig{Mode: machine.PinInput})
+	} else {
+		io.Configure(machine.PinConfig{Mode: machine.PinOutput})
+		io.Low()
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+// Configure the pulse & safety settings of all outputs from the configuration
+func (r *i2cRegisters) configurePulses() {
+	for idx, params := range r.config.Pulses {
+		r.outputGroups[idx/8].Configure(uint8(idx%8), params)
+	}
+}
+
+// Set the outputs of a PCF8574 output device
+func (r *i2cRegisters) setPCFOutputs(deviceIndex, value uint8) {
+	if int(deviceIndex)+1 >= len(r.outputGroups) {
+		return
+	}
+	r.sendPCFOutput(deviceIndex, r.outputGroups[1+deviceIndex].Set(value, millisSinceBoot()))
+}
+
+// Record the result of a write to a PCF8574 output device
+func (r *i2cRegisters) pcfOutputDone(result pcfOutput) {
+	dev := result.DeviceIndex
+	if int(dev) >= len(r.pcfFlags) {
+		return
+	}
+	if r.pcfPending[dev] > 0 {
+		r.pcfPending[dev]--
+	}
+	if result.Failed {
+		r.pcfFlags[dev] |= protocol.OutputFlagFailed
+	} else {
+		r.pcfApplied[dev] = result.Value
+		r.pcfFlags[dev] = protocol.OutputFlagWritten
+	}
+}
+
+// Returns the output status record of a PCF8574 output device
+func (r *i2cRegisters) pcfOutputStatus(dev uint8) []byte {
+	flags := r.pcfFlags[dev]
+	if r.pcfPending[dev] > 0 {
+		flags |= protocol.OutputFlagPending
+	}
+	return []byte{r.pcfApplied[dev], flags}
+}
+
+// Release outputs whose pulse ended or that exceeded their maximum on-time
+func (r *i2cRegisters) updatePulses() {
+	now := millisSinceBoot()
+	for idx := range r.outputGroups {
+		value, changed := r.outputGroups[idx].Update(now)
+		if !changed {
+			continue
+		}
+		if idx == 0 {
+			r.outputs = value
+			r.applyOutputs()
+		} else {
+			r.sendPCFOutput(uint8(idx-1), value)
+		}
+	}
+}
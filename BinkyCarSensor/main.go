@@ -6,6 +6,10 @@ import (
 	"time"
 
 	"tinygo.org/x/drivers/ws2812"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus/manager"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
 )
 
 var (
@@ -13,9 +17,11 @@ var (
 	colorBoot                      = color.RGBA{R: 255, G: 165, B: 0}
 	colorI2cConfigError            = color.RGBA{R: 96, G: 0, B: 96}
 	colorNoAdsDevsFound            = color.RGBA{R: 245, G: 0, B: 0}
-	colorTooManyAdsDevsFound       = color.RGBA{R: 96, G: 0, B: 0}
 	colorNoDetections1AdsDevFound  = color.RGBA{R: 0, G: 245, B: 0}
 	colorNoDetections2AdsDevsFound = color.RGBA{R: 0, G: 96, B: 0}
+	colorNoDetections3AdsDevsFound = color.RGBA{R: 0, G: 245, B: 96}
+	colorNoDetections4AdsDevsFound = color.RGBA{R: 0, G: 96, B: 96}
+	colorFailsafe                  = color.RGBA{R: 245, G: 245, B: 0}
 )
 
 var (
@@ -84,9 +90,19 @@ type pwm interface {
 	Enable(enable bool)
 }
 
+var (
+	// Time the firmware started
+	bootTime = time.Now()
+)
+
+// Returns the number of milliseconds since boot
+func millisSinceBoot() uint32 {
+	return uint32(time.Since(bootTime).Milliseconds())
+}
+
 const (
-	defaultI2cAddress = uint8(0x34)
-	altI2cAddress     = uint8(0x35)
+	defaultI2cAddress = protocol.DefaultI2CAddress
+	altI2cAddress     = protocol.AltI2CAddress
 )
 
 func main() {
@@ -104,14 +120,34 @@ func main() {
 	LedGreen.High() // Turn off
 	LedYellow.Low() // Turn on
 
+	// Read the cause of the last reset before the watchdog can be restarted
+	supervisor := newLoopSupervisor()
+
 	time.Sleep(time.Second * 5)
 
+	// Load configuration
+	configStore := config.NewStore(machine.Flash, 0)
+	cfg, configVersion, err := configStore.Load()
+	if err != nil {
+		println("Failed to load configuration, using defaults: ", err)
+		cfg = config.Default()
+		configVersion = 0
+	} else {
+		println("Loaded configuration version: ", configVersion)
+	}
+	detectionConfig := cfg.Detection
+
 	// Detect I2C address
 	i2cAddress := defaultI2cAddress
-	if !IO[0].Get() {
+	io0Locked := !IO[0].Get()
+	if io0Locked {
 		// IO1 pull down to GND
 		i2cAddress = altI2cAddress
 	}
+	if cfg.I2CAddress != 0 {
+		// Configured address overrides IO1
+		i2cAddress = cfg.I2CAddress
+	}
 	println("Found i2c address: ", i2cAddress)
 
 	// Configure neopixel
@@ -119,36 +155,52 @@ func main() {
 	led := ws2812.New(machine.NEOPIXEL)
 	led.WriteColors([]color.RGBA{colorBoot})
 
-	// Configure ADS1115 I2C channel (i2c0)
-	adsDevs, baseColor := probeADS1115Devices(led)
-
-	// Prepare sensor
-	sensors := make([]*Sensor, 0, len(adsDevs)*4)
-	for _, adsDev := range adsDevs {
-		sensors = append(sensors,
-			NewSensor(adsDev, 0),
-			NewSensor(adsDev, 1),
-			NewSensor(adsDev, 2),
-			NewSensor(adsDev, 3),
-		)
-	}
+	// Configure ADS1115 & PCF8574 I2C channel (i2c0)
+	configureI2C0(led)
+
+	// Serialize all transactions on i2c0.
+	// Outputs get priority over sensor polls.
+	bus := manager.New(machine.I2C0)
+	go bus.Run()
+	outputBus := bus.Client(manager.PriorityHigh)
+	sensorBus := bus.Client(manager.PriorityLow)
 
 	// Detect PCF8574 devices
-	pcfDevs := probePCF8574Devices(led)
+	pcfDevs := probePCF8574Devices(outputBus)
 
-	sensorStatus := make(chan uint8)
+	// Start sensor loop (detects ADS1115 devices in the background)
+	sensorStatus := make(chan carSensorStatus)
 	outputStatus := make(chan pcfOutput, 8)
-	go probeSensors(sensors, adsDevs, led, baseColor, sensorStatus)
-	go sendPCF8574Outputs(pcfDevs, outputStatus)
+	outputResults := make(chan pcfOutput, 8)
+	detectionChanges := make(chan config.Detection, 1)
+	calibrationRequests := make(chan uint16, 1)
+	calibrationResults := make(chan calibrationResult, protocol.MaxSensorCount)
+	ledUpdates := make(chan struct{}, 1)
+	go probeSensors(sensorBus, &statusLed{led: led, updates: ledUpdates}, sensorStatus, detectionConfig, detectionChanges,
+		calibrationRequests, calibrationResults, supervisor)
+	go sendPCF8574Outputs(pcfDevs, outputStatus, outputResults, supervisor)
+
+	// Prepare i2c registers
+	watchdog := &commWatchdog{}
+	watchdog.feed()
+	registers := newI2CRegisters(machine.I2C1, io0Locked, sensorStatus, outputStatus, outputResults,
+		uint8(len(pcfDevs)*8), bus,
+		configStore, cfg, configVersion, detectionChanges, calibrationRequests, calibrationResults, watchdog, ledUpdates, supervisor)
+	registers.applyPowerOnDefaults()
+	i2cEvents := make(chan incomingI2CEvent)
+	go registers.run(i2cEvents)
 	go func() {
 		for {
-			if err := listenForIncomingI2CRequests(machine.I2C1, i2cAddress, sensorStatus, outputStatus, uint8(len(adsDevs)*4), uint8(len(pcfDevs)*8)); err != nil {
+			if err := listenForIncomingI2CRequests(machine.I2C1, i2cAddress, i2cEvents, watchdog, supervisor); err != nil {
 				println("listenForIncomingI2CRequests failed: ", err)
 				time.Sleep(time.Second)
 			}
 		}
 	}()
 
+	// Reset the board when any of the loops above stalls
+	go supervisor.run()
+
 	// Set leds to running state
 	LedRed.High()                                  // Turn off
 	LedGreen.Low()                                 // Turn on
@@ -158,3 +210,16 @@ func main() {
 		time.Sleep(time.Minute)
 	}
 }
+
+// Configure I2C0 as controller, retrying until it succeeds
+func configureI2C0(led ws2812.Device) {
+	for {
+		println("Configure i2c0...")
+		if err := machine.I2C0.Configure(machine.I2CConfig{}); err == nil {
+			return
+		}
+		led.WriteColors([]color.RGBA{colorI2cConfigError})
+		time.Sleep(time.Second * 1)
+		led.WriteColors([]color.RGBA{colorBoot})
+	}
+}
@@ -0,0 +1,149 @@
+// Package pairs implements the measurement of the speed & direction of
+// cars passing a pair of car sensors along the same magnetic strip.
+// It is used by the firmware and by the fakeboard package, so both behave
+// the same.
+package pairs
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+const (
+	// Maximum time (ms) between the activations of both sensors of a pair.
+	// A car that takes longer is not measured.
+	MaxTransitTime = 10000
+)
+
+// Result is the last measurement of a pair.
+type Result struct {
+	// Set if the pair has measured a car
+	Valid bool
+	// Set if the car went from the second to the first sensor
+	Reverse bool
+	// Set if the measurement has not been read before
+	New bool
+	// Number of measurements (wraps)
+	Count uint8
+	// Time (ms) between the activations of both sensors
+	TransitTime uint16
+	// Speed of the car in millimeters per second
+	Speed uint16
+	// Scale speed of the car in km/h
+	ScaleSpeed uint16
+}
+
+// EncodeRecord encodes the result as register record of
+// protocol.PairResultRecordSize bytes.
+func (r Result) EncodeRecord() []byte {
+	flags := uint8(0)
+	if r.Valid {
+		flags |= protocol.PairFlagValid
+	}
+	if r.Reverse {
+		flags |= protocol.PairFlagReverse
+	}
+	if r.New {
+		flags |= protocol.PairFlagNew
+	}
+	return []byte{
+		flags,
+		r.Count,
+		uint8(r.TransitTime), uint8(r.TransitTime >> 8),
+		uint8(r.Speed), uint8(r.Speed >> 8),
+		uint8(r.ScaleSpeed), uint8(r.ScaleSpeed >> 8),
+	}
+}
+
+// Tracker times the activations of the sensors of all pairs.
+// It is not safe for concurrent use.
+type Tracker struct {
+	pairs [protocol.MaxSensorPairs]pair
+	state uint16 // Last status of all sensors
+}
+
+// State of a single pair
+type pair struct {
+	params     config.PairParams
+	pending    bool   // Set when one sensor has been activated
+	fromSecond bool   // Set when the second sensor was activated first
+	since      uint32 // Time (ms) the first of both sensors was activated
+	result     Result
+}
+
+// Configure the pair with given index.
+// Its pending & last measurement are reset.
+func (t *Tracker) Configure(idx int, params config.PairParams) {
+	t.pairs[idx] = pair{params: params}
+}
+
+// Update the tracker with the current status of all sensors, measured
+// at the given time (in milliseconds since boot).
+// Bit N is set when sensor N is active.
+func (t *Tracker) Update(x uint16, now uint32) {
+	rising := x &^ t.state
+	t.state = x
+	for idx := range t.pairs {
+		t.pairs[idx].update(rising, now)
+	}
+}
+
+// Result returns the last measurement of the pair with given index.
+func (t *Tracker) Result(idx int) Result {
+	return t.pairs[idx].result
+}
+
+// ReadRecord returns the last measurement of the pair with given index
+// as register record & marks it as read.
+func (t *Tracker) ReadRecord(idx int) []byte {
+	p := &t.pairs[idx]
+	record := p.result.EncodeRecord()
+	p.result.New = false
+	return record
+}
+
+// Process the sensors that became active
+func (p *pair) update(rising uint16, now uint32) {
+	if !p.params.IsEnabled() {
+		return
+	}
+	first := rising&(1<<p.params.First) != 0
+	second := rising&(1<<p.params.Second) != 0
+	if p.pending && now-p.since > MaxTransitTime {
+		// Car went elsewhere
+		p.pending = false
+	}
+	switch {
+	case first && second:
+		// Cannot tell which came first
+		p.pending = false
+	case !first && !second:
+		// No changes
+	case p.pending && p.fromSecond != second:
+		// Car reached the other sensor
+		p.measured(now - p.since)
+		p.pending = false
+	default:
+		// Car reached the first of both sensors
+		p.pending = true
+		p.fromSecond = second
+		p.since = now
+	}
+}
+
+// Store a measurement with given transit time (ms)
+func (p *pair) measured(transitTime uint32) {
+	transitTime = max(transitTime, 1)
+	speed := uint32(p.params.Spacing) * 1000 / transitTime
+	// mm/ms = m/s, 1 m/s = 3.6 km/h
+	scaleSpeed := (uint64(p.params.Spacing)*36*protocol.ModelScale + uint64(transitTime)*5) / (uint64(transitTime) * 10)
+	p.result = Result{
+		Valid:       true,
+		Reverse:     p.fromSecond,
+		New:         true,
+		Count:       p.result.Count + 1,
+		TransitTime: uint16(min(transitTime, 0xffff)),
+		Speed:       uint16(min(speed, 0xffff)),
+		ScaleSpeed:  uint16(min(scaleSpeed, 0xffff)),
+	}
+}
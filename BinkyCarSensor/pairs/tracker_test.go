@@ -0,0 +1,86 @@
+package pairs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+)
+
+func TestSpeedAndDirection(t *testing.T) {
+	tr := &Tracker{}
+	tr.Configure(0, config.PairParams{First: 2, Second: 3, Spacing: 100})
+
+	forward := Result{Valid: true, New: true, Count: 1, TransitTime: 500, Speed: 200, ScaleSpeed: 63}
+	reverse := Result{Valid: true, Reverse: true, New: true, Count: 2, TransitTime: 250, Speed: 400, ScaleSpeed: 125}
+	late := Result{Valid: true, Reverse: true, New: true, Count: 3, TransitTime: 100, Speed: 1000, ScaleSpeed: 313}
+	steps := []struct {
+		name     string
+		state    uint16
+		now      uint32
+		expected Result
+	}{
+		{"first sensor", 0x0004, 1000, Result{}},
+		{"first sensor released", 0x0000, 1100, Result{}},
+		{"second sensor", 0x0008, 1500, forward},
+		{"second sensor released", 0x0000, 1900, forward},
+		{"reverse second sensor", 0x0008, 2000, forward},
+		{"reverse first sensor", 0x000c, 2250, reverse},
+		{"both released", 0x0000, 3000, reverse},
+		{"both at once", 0x000c, 3100, reverse},
+		{"both released again", 0x0000, 3200, reverse},
+		{"first sensor again", 0x0004, 4000, reverse},
+		{"first sensor released again", 0x0000, 5000, reverse},
+		{"second sensor too late", 0x0008, 14001, reverse},
+		{"second sensor released again", 0x0000, 14050, reverse},
+		{"first sensor after late second", 0x0004, 14101, late},
+		{"other sensors", 0x0033, 14200, late},
+	}
+	for _, s := range steps {
+		tr.Update(s.state, s.now)
+		if r := tr.Result(0); r != s.expected {
+			t.Errorf("%s: expected %+v, got %+v", s.name, s.expected, r)
+		}
+	}
+	if r := tr.Result(1); r.Valid {
+		t.Errorf("expected disabled pair to have no measurement, got %+v", r)
+	}
+}
+
+func TestMaxTransitTime(t *testing.T) {
+	tr := &Tracker{}
+	tr.Configure(1, config.PairParams{First: 0, Second: 1, Spacing: 50})
+	// Across a timer wrap
+	start := uint32(0xffffff00)
+	tr.Update(0x0001, start)
+	tr.Update(0x0003, start+MaxTransitTime)
+	expected := Result{Valid: true, New: true, Count: 1, TransitTime: MaxTransitTime, Speed: 5, ScaleSpeed: 2}
+	if r := tr.Result(1); r != expected {
+		t.Errorf("expected %+v, got %+v", expected, r)
+	}
+	// Configure resets the measurement
+	tr.Configure(1, config.PairParams{First: 0, Second: 1, Spacing: 50})
+	if r := tr.Result(1); r != (Result{}) {
+		t.Errorf("expected no measurement after configure, got %+v", r)
+	}
+}
+
+func TestReadRecord(t *testing.T) {
+	tr := &Tracker{}
+	tr.Configure(0, config.PairParams{First: 0, Second: 1, Spacing: 100})
+	tr.Update(0x0001, 0)
+	tr.Update(0x0003, 500)
+
+	tests := []struct {
+		name     string
+		expected []byte
+	}{
+		{"new measurement", []byte{0x05, 1, 0xf4, 0x01, 200, 0, 63, 0}},
+		{"read before", []byte{0x01, 1, 0xf4, 0x01, 200, 0, 63, 0}},
+	}
+	for _, test := range tests {
+		if record := tr.ReadRecord(0); !bytes.Equal(record, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, record)
+		}
+	}
+}
@@ -2,22 +2,32 @@ package main
 
 import (
 	"github.com/binkynet/BinkyHardware/BinkyCarSensor/devices/pcf8574"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
 )
 
 type pcfOutput struct {
 	DeviceIndex uint8
 	Value       uint8
+	Failed      bool // Set in results when the write failed
 }
 
-// Keep probing sensors
-func sendPCF8574Outputs(devices []*pcf8574.Device, outputStatus <-chan pcfOutput) {
+// Keep writing PCF8574 outputs, reporting the result of every write
+func sendPCF8574Outputs(devices []*pcf8574.Device, outputStatus <-chan pcfOutput, results chan<- pcfOutput, supervisor *loopSupervisor) {
 	devCnt := uint8(len(devices))
 	for {
+		supervisor.wait(protocol.LoopPCFOutput)
 		select {
 		case output := <-outputStatus:
+			supervisor.alive(protocol.LoopPCFOutput)
 			if output.DeviceIndex < devCnt {
-				devices[output.DeviceIndex].WriteBits(output.Value)
+				if err := devices[output.DeviceIndex].WriteBits(output.Value); err != nil {
+					println("Failed to write PCF output: ", output.DeviceIndex, err.Error())
+					output.Failed = true
+				}
+			} else {
+				output.Failed = true
 			}
+			results <- output
 		}
 	}
 }
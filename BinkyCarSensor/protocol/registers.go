@@ -0,0 +1,446 @@
+// Package protocol defines the I2C register protocol that a BinkyCarSensor
+// board exposes (on I2C1) to a Binky local-worker.
+//
+// Reading a register is done by writing the register address, followed by
+// a read of the response bytes.
+// Writing a register is done by writing the register address, followed by
+// the value byte(s).
+package protocol
+
+const (
+	// I2C addresses of the board
+	DefaultI2CAddress = uint8(0x34) // IO1 not connected
+	AltI2CAddress     = uint8(0x35) // IO1 pulled down to GND
+
+	// Range of valid (configurable) I2C addresses
+	MinI2CAddress = uint8(0x08)
+	MaxI2CAddress = uint8(0x77)
+)
+
+const (
+	// Register addresses
+	RegVersionMajor   = 0x00 // No input, returns 1 version
+	RegVersionMinor   = 0x01 // No input, returns 1 version
+	RegVersionPatch   = 0x02 // No input, returns 1 version
+	RegCarSensorCount = 0x03 // No input, returns 1 byte giving the number of detected car sensor bits (0..16)
+	RegI2COutputCount = 0x04 // No input, returns 1 byte giving the number of detected I2C binary output pins (0, 8, 16, ..., 256)
+	RegCarSensorState = 0x10 // No input, returns 2 bytes (LSB first) with 16-bit car detection sensor state
+	RegOutput         = 0x20 // 1 byte input, targeting 8 on-pcb output pins, returns OutputStatusSize bytes
+	RegOutputI2C0     = 0x21 // 1 byte input, targeting 8 output pins on PCF8574 output device 0, returns OutputStatusSize bytes
+	RegOutputI2C1     = 0x22 // 1 byte input, targeting 8 output pins on PCF8574 output device 1, returns OutputStatusSize bytes
+	RegOutputI2C2     = 0x23 // 1 byte input, targeting 8 output pins on PCF8574 output device 2, returns OutputStatusSize bytes
+	RegOutputI2C3     = 0x24 // 1 byte input, targeting 8 output pins on PCF8574 output device 3, returns OutputStatusSize bytes
+	RegOutputI2C4     = 0x25 // 1 byte input, targeting 8 output pins on PCF8574 output device 4, returns OutputStatusSize bytes
+	RegOutputI2C5     = 0x26 // 1 byte input, targeting 8 output pins on PCF8574 output device 5, returns OutputStatusSize bytes
+	RegOutputI2C6     = 0x27 // 1 byte input, targeting 8 output pins on PCF8574 output device 6, returns OutputStatusSize bytes
+	RegOutputI2C7     = 0x28 // 1 byte input, targeting 8 output pins on PCF8574 output device 7, returns OutputStatusSize bytes
+	RegConfigurePWM0  = 0x30 // 1 byte input, pwm-value (0-255, 255 = fully on) of pin 0, returns 1 byte (0 when not in PWM mode)
+	RegConfigurePWM1  = 0x31 // 1 byte input, pwm-value (0-255, 255 = fully on) of pin 1, returns 1 byte (0 when not in PWM mode)
+	RegConfigurePWM2  = 0x32 // 1 byte input, pwm-value (0-255, 255 = fully on) of pin 2, returns 1 byte (0 when not in PWM mode)
+	RegConfigurePWM3  = 0x33 // 1 byte input, pwm-value (0-255, 255 = fully on) of pin 3, returns 1 byte (0 when not in PWM mode)
+	RegConfigurePWM4  = 0x34 // 1 byte input, pwm-value (0-255, 255 = fully on) of pin 4, returns 1 byte (0 when not in PWM mode)
+	RegConfigurePWM5  = 0x35 // 1 byte input, pwm-value (0-255, 255 = fully on) of pin 5, returns 1 byte (0 when not in PWM mode)
+	RegConfigurePWM6  = 0x36 // 1 byte input, pwm-value (0-255, 255 = fully on) of pin 6, returns 1 byte (0 when not in PWM mode)
+	RegConfigurePWM7  = 0x37 // 1 byte input, pwm-value (0-255, 255 = fully on) of pin 7, returns 1 byte (0 when not in PWM mode)
+
+	// PWM status
+	RegPWMConflicts = 0x38 // No input, returns 1 byte with pins whose last PWM request was rejected because of another pin on the same PWM slice, 1 byte input (mask) clears
+	RegPWMRelease   = 0x39 // 1 byte input (mask), returns pins from PWM mode to digital output, returns 1 byte with pins in PWM mode
+
+	// 16-bit PWM duty cycle & frequency
+	RegPWMDuty0      = 0x70 // 2 bytes input (LSB first), pwm duty cycle (0-0xffff, 0xffff = fully on) of pin 0, returns 2 bytes
+	RegPWMDuty1      = 0x71 // 2 bytes input (LSB first), pwm duty cycle (0-0xffff, 0xffff = fully on) of pin 1, returns 2 bytes
+	RegPWMDuty2      = 0x72 // 2 bytes input (LSB first), pwm duty cycle (0-0xffff, 0xffff = fully on) of pin 2, returns 2 bytes
+	RegPWMDuty3      = 0x73 // 2 bytes input (LSB first), pwm duty cycle (0-0xffff, 0xffff = fully on) of pin 3, returns 2 bytes
+	RegPWMDuty4      = 0x74 // 2 bytes input (LSB first), pwm duty cycle (0-0xffff, 0xffff = fully on) of pin 4, returns 2 bytes
+	RegPWMDuty5      = 0x75 // 2 bytes input (LSB first), pwm duty cycle (0-0xffff, 0xffff = fully on) of pin 5, returns 2 bytes
+	RegPWMDuty6      = 0x76 // 2 bytes input (LSB first), pwm duty cycle (0-0xffff, 0xffff = fully on) of pin 6, returns 2 bytes
+	RegPWMDuty7      = 0x77 // 2 bytes input (LSB first), pwm duty cycle (0-0xffff, 0xffff = fully on) of pin 7, returns 2 bytes
+	RegPWMFrequency0 = 0x78 // 2 bytes input (LSB first), pwm frequency in Hz of pin 0 (0 = default), shared by pins on the same PWM slice, returns 2 bytes
+	RegPWMFrequency1 = 0x79 // 2 bytes input (LSB first), pwm frequency in Hz of pin 1 (0 = default), shared by pins on the same PWM slice, returns 2 bytes
+	RegPWMFrequency2 = 0x7A // 2 bytes input (LSB first), pwm frequency in Hz of pin 2 (0 = default), shared by pins on the same PWM slice, returns 2 bytes
+	RegPWMFrequency3 = 0x7B // 2 bytes input (LSB first), pwm frequency in Hz of pin 3 (0 = default), shared by pins on the same PWM slice, returns 2 bytes
+	RegPWMFrequency4 = 0x7C // 2 bytes input (LSB first), pwm frequency in Hz of pin 4 (0 = default), shared by pins on the same PWM slice, returns 2 bytes
+	RegPWMFrequency5 = 0x7D // 2 bytes input (LSB first), pwm frequency in Hz of pin 5 (0 = default), shared by pins on the same PWM slice, returns 2 bytes
+	RegPWMFrequency6 = 0x7E // 2 bytes input (LSB first), pwm frequency in Hz of pin 6 (0 = default), shared by pins on the same PWM slice, returns 2 bytes
+	RegPWMFrequency7 = 0x7F // 2 bytes input (LSB first), pwm frequency in Hz of pin 7 (0 = default), shared by pins on the same PWM slice, returns 2 bytes
+
+	// Car sensor edges & pass counters
+	RegCarSensorRisingEdges     = 0x11 // No input, returns 2 bytes (LSB first) with car sensors that became active since last acknowledge
+	RegCarSensorFallingEdges    = 0x12 // No input, returns 2 bytes (LSB first) with car sensors that became inactive since last acknowledge
+	RegCarSensorAckEdges        = 0x13 // 2 bytes input (LSB first), clears rising & falling edges of car sensors in the mask
+	RegCarSensorResetPassCounts = 0x14 // 2 bytes input (LSB first), resets pass counters of car sensors in the mask
+	RegCarSensorEventStatus     = 0x15 // No input, returns 2 bytes: number of queued car sensor events, flags (EventFlagOverflow)
+	RegCarSensorEvent           = 0x16 // No input, returns EventRecordSize bytes with the oldest queued car sensor event & removes it from the queue
+	RegCarSensorPolarity        = 0x17 // No input, returns 2 bytes (LSB first) with car sensors whose current (or last) detection has negative polarity
+	RegCarSensorPassCount0      = 0x40 // No input, returns 2 bytes (LSB first) with the number of times car sensor 0 became active (wraps)
+	RegCarSensorPassCount1      = 0x41 // No input, returns 2 bytes (LSB first) with the number of times car sensor 1 became active (wraps)
+	RegCarSensorPassCount2      = 0x42 // No input, returns 2 bytes (LSB first) with the number of times car sensor 2 became active (wraps)
+	RegCarSensorPassCount3      = 0x43 // No input, returns 2 bytes (LSB first) with the number of times car sensor 3 became active (wraps)
+	RegCarSensorPassCount4      = 0x44 // No input, returns 2 bytes (LSB first) with the number of times car sensor 4 became active (wraps)
+	RegCarSensorPassCount5      = 0x45 // No input, returns 2 bytes (LSB first) with the number of times car sensor 5 became active (wraps)
+	RegCarSensorPassCount6      = 0x46 // No input, returns 2 bytes (LSB first) with the number of times car sensor 6 became active (wraps)
+	RegCarSensorPassCount7      = 0x47 // No input, returns 2 bytes (LSB first) with the number of times car sensor 7 became active (wraps)
+	RegCarSensorPassCount8      = 0x48 // No input, returns 2 bytes (LSB first) with the number of times car sensor 8 became active (wraps)
+	RegCarSensorPassCount9      = 0x49 // No input, returns 2 bytes (LSB first) with the number of times car sensor 9 became active (wraps)
+	RegCarSensorPassCount10     = 0x4A // No input, returns 2 bytes (LSB first) with the number of times car sensor 10 became active (wraps)
+	RegCarSensorPassCount11     = 0x4B // No input, returns 2 bytes (LSB first) with the number of times car sensor 11 became active (wraps)
+	RegCarSensorPassCount12     = 0x4C // No input, returns 2 bytes (LSB first) with the number of times car sensor 12 became active (wraps)
+	RegCarSensorPassCount13     = 0x4D // No input, returns 2 bytes (LSB first) with the number of times car sensor 13 became active (wraps)
+	RegCarSensorPassCount14     = 0x4E // No input, returns 2 bytes (LSB first) with the number of times car sensor 14 became active (wraps)
+	RegCarSensorPassCount15     = 0x4F // No input, returns 2 bytes (LSB first) with the number of times car sensor 15 became active (wraps)
+
+	// Detection configuration
+	RegDetectionParams = 0x50 // 1 byte input (sensor index or DetectionGlobal) selects, 1+DetectionRecordSize bytes input sets, returns DetectionRecordSize bytes of the selected sensor
+	RegProbeInterval   = 0x51 // 2 bytes input (LSB first), interval between sensor probes in milliseconds, returns 2 bytes
+	RegDetector        = 0x55 // 1 byte input (sensor index) selects, 1+DetectorRecordSize bytes input sets, returns DetectorRecordSize bytes of the selected sensor
+	RegPresenceMode    = 0x56 // 2 bytes input (LSB first), car sensors in the mask stay active while a car is present (requires calibration), returns 2 bytes
+
+	// Car sensor calibration
+	RegCalibration     = 0x52 // 1 byte input (sensor index) selects, 1+CalibrationRecordSize bytes input sets, returns CalibrationRecordSize bytes of the selected sensor
+	RegCalibrate       = 0x53 // 2 bytes input (LSB first), starts calibration of car sensors in the mask, returns 2 bytes with car sensors being calibrated
+	RegCalibrationMode = 0x54 // 1 byte input, calibration flags (CalibrationModeXyz), returns 1 byte
+
+	// Board configuration
+	RegConfigStatus         = 0x58 // No input, returns 2 bytes: version of the stored configuration (0 if none), flags (ConfigFlagXyz)
+	RegConfigI2CAddress     = 0x59 // 1 byte input, I2C address used after next boot (0 = selected by IO1), returns 1 byte
+	RegConfigPinModes       = 0x5A // 1-8 bytes input, power-on mode (PinModeXyz) of pin 0..7, returns 8 bytes
+	RegConfigOutputDefaults = 0x5B // 1-9 bytes input, power-on value of on-pcb output pins, PCF8574 output device 0..7, returns 9 bytes
+	RegConfigPWMDefaults    = 0x5C // 1-8 bytes input, power-on pwm-value (or servo position) of pin 0..7, returns 8 bytes
+	RegConfigLabel          = 0x5D // 1-LabelMaxSize bytes input, label of the board, returns LabelMaxSize bytes (padded with 0)
+	RegFactoryReset         = 0x5E // 1 byte input (FactoryResetMagic), erases the configuration from flash & restores defaults
+	RegSaveConfig           = 0x5F // 1 byte input (ConfigSaveMagic), stores the current configuration in flash
+
+	// I2C0 bus statistics
+	RegBusStats      = 0x60 // 1 byte input (I2C address of device on I2C0) selects, returns BusStatsRecordSize bytes of the selected device
+	RegBusResetStats = 0x61 // 1 byte input (any value), resets the statistics of all devices on I2C0
+
+	// Servos
+	RegServoTarget0 = 0x80 // 1 byte input, target position (0-255) of the servo on pin 0 (switches pin to servo mode), returns ServoStatusSize bytes
+	RegServoTarget1 = 0x81 // 1 byte input, target position (0-255) of the servo on pin 1 (switches pin to servo mode), returns ServoStatusSize bytes
+	RegServoTarget2 = 0x82 // 1 byte input, target position (0-255) of the servo on pin 2 (switches pin to servo mode), returns ServoStatusSize bytes
+	RegServoTarget3 = 0x83 // 1 byte input, target position (0-255) of the servo on pin 3 (switches pin to servo mode), returns ServoStatusSize bytes
+	RegServoTarget4 = 0x84 // 1 byte input, target position (0-255) of the servo on pin 4 (switches pin to servo mode), returns ServoStatusSize bytes
+	RegServoTarget5 = 0x85 // 1 byte input, target position (0-255) of the servo on pin 5 (switches pin to servo mode), returns ServoStatusSize bytes
+	RegServoTarget6 = 0x86 // 1 byte input, target position (0-255) of the servo on pin 6 (switches pin to servo mode), returns ServoStatusSize bytes
+	RegServoTarget7 = 0x87 // 1 byte input, target position (0-255) of the servo on pin 7 (switches pin to servo mode), returns ServoStatusSize bytes
+	RegServoConfig0 = 0x88 // ServoRecordSize bytes input, servo parameters of pin 0, returns ServoRecordSize bytes
+	RegServoConfig1 = 0x89 // ServoRecordSize bytes input, servo parameters of pin 1, returns ServoRecordSize bytes
+	RegServoConfig2 = 0x8A // ServoRecordSize bytes input, servo parameters of pin 2, returns ServoRecordSize bytes
+	RegServoConfig3 = 0x8B // ServoRecordSize bytes input, servo parameters of pin 3, returns ServoRecordSize bytes
+	RegServoConfig4 = 0x8C // ServoRecordSize bytes input, servo parameters of pin 4, returns ServoRecordSize bytes
+	RegServoConfig5 = 0x8D // ServoRecordSize bytes input, servo parameters of pin 5, returns ServoRecordSize bytes
+	RegServoConfig6 = 0x8E // ServoRecordSize bytes input, servo parameters of pin 6, returns ServoRecordSize bytes
+	RegServoConfig7 = 0x8F // ServoRecordSize bytes input, servo parameters of pin 7, returns ServoRecordSize bytes
+
+	// Light effects
+	RegEffect0 = 0x90 // EffectRecordSize bytes input, light effect of pin 0 (EffectNone returns pin to digital output), returns EffectRecordSize bytes
+	RegEffect1 = 0x91 // EffectRecordSize bytes input, light effect of pin 1 (EffectNone returns pin to digital output), returns EffectRecordSize bytes
+	RegEffect2 = 0x92 // EffectRecordSize bytes input, light effect of pin 2 (EffectNone returns pin to digital output), returns EffectRecordSize bytes
+	RegEffect3 = 0x93 // EffectRecordSize bytes input, light effect of pin 3 (EffectNone returns pin to digital output), returns EffectRecordSize bytes
+	RegEffect4 = 0x94 // EffectRecordSize bytes input, light effect of pin 4 (EffectNone returns pin to digital output), returns EffectRecordSize bytes
+	RegEffect5 = 0x95 // EffectRecordSize bytes input, light effect of pin 5 (EffectNone returns pin to digital output), returns EffectRecordSize bytes
+	RegEffect6 = 0x96 // EffectRecordSize bytes input, light effect of pin 6 (EffectNone returns pin to digital output), returns EffectRecordSize bytes
+	RegEffect7 = 0x97 // EffectRecordSize bytes input, light effect of pin 7 (EffectNone returns pin to digital output), returns EffectRecordSize bytes
+
+	// Pulse outputs
+	RegPulseConfig  = 0xA0 // 1 byte input (output index) selects, 1+PulseRecordSize bytes input sets, returns PulseRecordSize bytes of the selected output
+	RegPulseTripped = 0xA1 // No input, returns 1+MaxPCFDevices bytes with outputs switched off by their maximum on-time (on-pcb, PCF8574 device 0..7), 1-9 bytes input (masks) clears
+
+	// Communication-loss failsafe
+	RegFailsafeStatus  = 0xA8 // No input, returns 1 byte with flags (FailsafeFlagXyz), 1 byte input (any value) clears FailsafeFlagTriggered
+	RegFailsafeTimeout = 0xA9 // 2 bytes input (LSB first), time in milliseconds without valid transaction before the failsafe triggers (0 = disabled), returns 2 bytes
+	RegFailsafeOutputs = 0xAA // 1-9 bytes input, safe value of on-pcb output pins, PCF8574 output device 0..7, returns 9 bytes
+	RegFailsafePWM     = 0xAB // 1-8 bytes input, safe pwm-value (or servo position) of pin 0..7, returns 8 bytes
+
+	// Hardware watchdog
+	RegResetStatus = 0xB0 // No input, returns ResetStatusSize bytes with the cause of the last reset
+
+	// Digital inputs
+	RegInputState        = 0xB4 // No input, returns 1 byte with inputs that have been active since the last read
+	RegInputRisingEdges  = 0xB5 // No input, returns 1 byte with inputs that became active since last acknowledge
+	RegInputFallingEdges = 0xB6 // No input, returns 1 byte with inputs that became inactive since last acknowledge
+	RegInputAckEdges     = 0xB7 // 1 byte input (mask), clears rising & falling edges of inputs in the mask
+	RegInputConfig0      = 0xB8 // InputRecordSize bytes input, input settings of pin 0 (InputModeNone returns pin to digital output), returns InputRecordSize bytes
+	RegInputConfig1      = 0xB9 // InputRecordSize bytes input, input settings of pin 1 (InputModeNone returns pin to digital output), returns InputRecordSize bytes
+	RegInputConfig2      = 0xBA // InputRecordSize bytes input, input settings of pin 2 (InputModeNone returns pin to digital output), returns InputRecordSize bytes
+	RegInputConfig3      = 0xBB // InputRecordSize bytes input, input settings of pin 3 (InputModeNone returns pin to digital output), returns InputRecordSize bytes
+	RegInputConfig4      = 0xBC // InputRecordSize bytes input, input settings of pin 4 (InputModeNone returns pin to digital output), returns InputRecordSize bytes
+	RegInputConfig5      = 0xBD // InputRecordSize bytes input, input settings of pin 5 (InputModeNone returns pin to digital output), returns InputRecordSize bytes
+	RegInputConfig6      = 0xBE // InputRecordSize bytes input, input settings of pin 6 (InputModeNone returns pin to digital output), returns InputRecordSize bytes
+	RegInputConfig7      = 0xBF // InputRecordSize bytes input, input settings of pin 7 (InputModeNone returns pin to digital output), returns InputRecordSize bytes
+
+	// Pin modes
+	RegPinMode0  = 0xC0 // 1 byte input, mode (PinModeXyz) of pin 0, returns 1 byte with the current mode
+	RegPinMode1  = 0xC1 // 1 byte input, mode (PinModeXyz) of pin 1, returns 1 byte with the current mode
+	RegPinMode2  = 0xC2 // 1 byte input, mode (PinModeXyz) of pin 2, returns 1 byte with the current mode
+	RegPinMode3  = 0xC3 // 1 byte input, mode (PinModeXyz) of pin 3, returns 1 byte with the current mode
+	RegPinMode4  = 0xC4 // 1 byte input, mode (PinModeXyz) of pin 4, returns 1 byte with the current mode
+	RegPinMode5  = 0xC5 // 1 byte input, mode (PinModeXyz) of pin 5, returns 1 byte with the current mode
+	RegPinMode6  = 0xC6 // 1 byte input, mode (PinModeXyz) of pin 6, returns 1 byte with the current mode
+	RegPinMode7  = 0xC7 // 1 byte input, mode (PinModeXyz) of pin 7, returns 1 byte with the current mode
+	RegPinErrors = 0xC8 // No input, returns PinErrorRecordSize bytes with pins whose last request was rejected, 1 byte input (mask) clears
+
+	// Car sensor pairs
+	RegPairConfig0 = 0xD0 // PairConfigRecordSize bytes input, sensors & spacing of pair 0, returns PairConfigRecordSize bytes
+	RegPairConfig1 = 0xD1 // PairConfigRecordSize bytes input, sensors & spacing of pair 1, returns PairConfigRecordSize bytes
+	RegPairConfig2 = 0xD2 // PairConfigRecordSize bytes input, sensors & spacing of pair 2, returns PairConfigRecordSize bytes
+	RegPairConfig3 = 0xD3 // PairConfigRecordSize bytes input, sensors & spacing of pair 3, returns PairConfigRecordSize bytes
+	RegPairResult0 = 0xD8 // No input, returns PairResultRecordSize bytes with the last measurement of pair 0
+	RegPairResult1 = 0xD9 // No input, returns PairResultRecordSize bytes with the last measurement of pair 1
+	RegPairResult2 = 0xDA // No input, returns PairResultRecordSize bytes with the last measurement of pair 2
+	RegPairResult3 = 0xDB // No input, returns PairResultRecordSize bytes with the last measurement of pair 3
+)
+
+const (
+	// Size of a car sensor event record:
+	// flags, sensor index, timestamp (4 bytes, LSB first, milliseconds since boot)
+	EventRecordSize = 6
+
+	// Car sensor event flags
+	EventFlagValid    = uint8(0x01) // Set if the record contains an event (unset if the queue was empty)
+	EventFlagActive   = uint8(0x02) // Set if the sensor became active, unset if it became inactive
+	EventFlagNegative = uint8(0x04) // Set if the detection has negative polarity (sensor output below its baseline)
+	EventFlagOverflow = uint8(0x80) // Set if events have been dropped since the queue was last drained
+)
+
+const (
+	// Size of a detection parameters record:
+	// flags, lag, window size, threshold (2 bytes, 1/100), influence (2 bytes, 1/100), min-max difference (2 bytes).
+	// Multi-byte values are LSB first.
+	DetectionRecordSize = 9
+
+	// Detection parameters flags
+	DetectionFlagOverride = uint8(0x01) // Set if the sensor uses its own parameters instead of the global parameters
+
+	// Sensor index used to select the global detection parameters
+	DetectionGlobal = uint8(0xff)
+
+	// Value to write to RegSaveConfig to store the configuration
+	ConfigSaveMagic = uint8(0xa5)
+	// Value to write to RegFactoryReset to restore the default configuration
+	FactoryResetMagic = uint8(0x5a)
+
+	// Configuration status flags
+	ConfigFlagLoaded   = uint8(0x01) // Set if the configuration was loaded from flash
+	ConfigFlagMigrated = uint8(0x02) // Set if the configuration was stored by an older firmware & migrated
+	ConfigFlagModified = uint8(0x04) // Set if the configuration has been modified since it was loaded or saved
+
+	// Maximum size of the board label
+	LabelMaxSize = 15
+)
+
+const (
+	// Size of a calibration record:
+	// flags (CalibrationFlagXyz), baseline (2 bytes, raw ADS1115 value), noise (2 bytes, standard deviation of raw value).
+	// Multi-byte values are LSB first.
+	CalibrationRecordSize = 5
+
+	// Calibration flags
+	CalibrationFlagValid = uint8(0x01) // Set if the sensor has been calibrated
+
+	// Calibration modes
+	CalibrationModeAtBoot = uint8(0x01) // Calibrate all car sensors when they are found after boot
+)
+
+const (
+	// Size of a detector record:
+	// detector (DetectorXyz), on threshold (2 bytes), off threshold (2 bytes), minimum on time (2 bytes, milliseconds).
+	// Thresholds are deviations of the raw ADS1115 value from the calibrated baseline.
+	// Multi-byte values are LSB first.
+	DetectorRecordSize = 7
+
+	// Detectors
+	DetectorZScore     = uint8(0) // Peak detector on the z-score of the probe values (tuned in RegDetectionParams)
+	DetectorHysteresis = uint8(1) // Active above the on threshold, inactive below the off threshold (requires calibration)
+)
+
+// IsValidDetector returns true if the given detector is supported.
+func IsValidDetector(detector uint8) bool {
+	switch detector {
+	case DetectorZScore, DetectorHysteresis:
+		return true
+	default:
+		return false
+	}
+}
+
+const (
+	// Size of a car sensor pair configuration record:
+	// first sensor index, second sensor index, spacing (2 bytes, LSB first, millimeters, 0 disables the pair).
+	PairConfigRecordSize = 4
+
+	// Size of a car sensor pair result record:
+	// flags (PairFlagXyz), number of measurements (wraps), transit time (2 bytes, milliseconds),
+	// speed (2 bytes, millimeters per second), scale speed (2 bytes, km/h).
+	// Multi-byte values are LSB first.
+	PairResultRecordSize = 8
+
+	// Car sensor pair result flags
+	PairFlagValid   = uint8(0x01) // Set if the pair has measured a car
+	PairFlagReverse = uint8(0x02) // Set if the car went from the second to the first sensor
+	PairFlagNew     = uint8(0x04) // Set if the measurement has not been read before
+)
+
+const (
+	// Size of a bus statistics record:
+	// I2C address, number of transactions (4 bytes), number of failed transactions (4 bytes).
+	// Multi-byte values are LSB first.
+	BusStatsRecordSize = 9
+)
+
+const (
+	// Size of a servo status record:
+	// target position, current position, flags (ServoFlagXyz)
+	ServoStatusSize = 3
+
+	// Servo status flags
+	ServoFlagMoving = uint8(0x01) // Set while the servo moves towards its target
+	ServoFlagSignal = uint8(0x02) // Set while pulses are sent to the servo
+
+	// Size of a servo parameters record:
+	// min pulse (2 bytes, us), max pulse (2 bytes, us), speed (2 bytes, positions per second),
+	// cut-off delay (2 bytes, ms). Multi-byte values are LSB first.
+	ServoRecordSize = 8
+)
+
+const (
+	// Size of a light effect record:
+	// kind (EffectXyz), brightness (0-255), on time (2 bytes, ms), off time (2 bytes, ms), partner pin.
+	// Multi-byte values are LSB first.
+	// Blink & alternate: on for on time, off for off time. Alternate drives partner pin in opposite phase.
+	// Fade: fade in during on time, fade out during off time.
+	// Flicker: random brightness changes at random intervals between on time & off time.
+	// Dim: constant brightness.
+	EffectRecordSize = 7
+
+	// Light effect kinds
+	EffectNone      = uint8(0x00)
+	EffectBlink     = uint8(0x01)
+	EffectAlternate = uint8(0x02)
+	EffectFade      = uint8(0x03)
+	EffectFlicker   = uint8(0x04)
+	EffectDim       = uint8(0x05)
+)
+
+const (
+	// Size of a pulse output record:
+	// pulse duration (2 bytes, ms, 0 = no pulse), maximum on-time (2 bytes, ms, 0 = no limit).
+	// Multi-byte values are LSB first.
+	PulseRecordSize = 4
+
+	// Number of outputs with pulse settings.
+	// Output index = 8*group + bit, where group 0 is the on-pcb output pins
+	// and group 1+N is PCF8574 output device N.
+	PulseOutputCount = (1 + MaxPCFDevices) * 8
+)
+
+const (
+	// Size of an output status record:
+	// value last applied to the outputs, flags (OutputFlagXyz)
+	OutputStatusSize = 2
+
+	// Output status flags
+	OutputFlagWritten = uint8(0x01) // Set if the value has been applied to the outputs
+	OutputFlagFailed  = uint8(0x02) // Set if the last write to the outputs failed (PCF8574 devices only)
+	OutputFlagPending = uint8(0x04) // Set while writes to the outputs are queued (PCF8574 devices only)
+)
+
+const (
+	// Failsafe status flags
+	FailsafeFlagActive    = uint8(0x01) // Set while outputs are in their safe state because of communication loss
+	FailsafeFlagTriggered = uint8(0x02) // Set if the failsafe triggered since the flag was last cleared
+)
+
+const (
+	// Size of a reset status record:
+	// reset cause (ResetCauseXyz), loops that stalled before a watchdog reset (LoopXyz mask)
+	ResetStatusSize = 2
+
+	// Reset causes
+	ResetCausePowerOn  = uint8(0x00) // Power-on, reset button or software reset
+	ResetCauseWatchdog = uint8(0x01) // Watchdog timer expired
+	ResetCauseForced   = uint8(0x02) // Reset forced through the watchdog
+
+	// Firmware loops supervised by the watchdog
+	LoopSensor    = uint8(0x01) // Sensor loop (probing ADS1115 devices)
+	LoopPCFOutput = uint8(0x02) // Output loop (writing PCF8574 devices)
+	LoopRegisters = uint8(0x04) // Register loop (handling i2c events & updating on-pcb outputs)
+	LoopListener  = uint8(0x08) // I2C listener (receiving i2c events)
+)
+
+const (
+	// Size of an input settings record:
+	// input mode (InputModeXyz), debounce time (2 bytes, ms, LSB first)
+	InputRecordSize = 3
+
+	// Input modes.
+	// Inputs with pull-up are active when low (e.g. a button to GND),
+	// inputs with pull-down are active when high.
+	InputModeNone     = uint8(0x00) // Pin is not used as input
+	InputModePullUp   = uint8(0x01) // Input with pull-up resistor
+	InputModePullDown = uint8(0x02) // Input with pull-down resistor
+)
+
+// IsValidInputMode returns true if the given mode is a valid input mode.
+func IsValidInputMode(mode uint8) bool {
+	switch mode {
+	case InputModeNone, InputModePullUp, InputModePullDown:
+		return true
+	default:
+		return false
+	}
+}
+
+const (
+	// Pin modes
+	PinModeOutput    = uint8(0x00) // Digital output (default)
+	PinModePWM       = uint8(0x01) // PWM output (also used by light effects)
+	PinModeServo     = uint8(0x02) // Hobby servo output
+	PinModeDisabled  = uint8(0x03) // Not driven, no pull-up or pull-down
+	PinModeOpenDrain = uint8(0x04) // Open-drain output, driven low for 0, released for 1
+	PinModeInput     = uint8(0x05) // Digital input (see RegInputConfigx)
+
+	// Size of a pin errors record:
+	// pins whose last request was rejected (mask), reason of the last rejection (PinErrorXyz)
+	PinErrorRecordSize = 2
+
+	// Reasons for rejecting a pin request
+	PinErrorNone         = uint8(0x00)
+	PinErrorInvalidMode  = uint8(0x01) // Unknown pin mode
+	PinErrorAddressStrap = uint8(0x02) // IO0 cannot be driven high while it selects the alternate i2c address
+	PinErrorNotOutput    = uint8(0x03) // Pin is disabled or an input, so it cannot be driven
+)
+
+// IsValidPinMode returns true if the given mode is a valid pin mode.
+func IsValidPinMode(mode uint8) bool {
+	switch mode {
+	case PinModeOutput, PinModePWM, PinModeServo, PinModeDisabled, PinModeOpenDrain, PinModeInput:
+		return true
+	default:
+		return false
+	}
+}
+
+const (
+	// Number of on-pcb IO pins
+	IOPinCount = 8
+	// Maximum number of ADS1115 devices
+	MaxADSDevices = 4
+	// Number of car sensors per ADS1115 device
+	SensorsPerADSDevice = 4
+	// Maximum number of car sensors
+	MaxSensorCount = MaxADSDevices * SensorsPerADSDevice
+	// Maximum number of PCF8574 output devices
+	MaxPCFDevices = 8
+	// Maximum number of car sensor pairs
+	MaxSensorPairs = 4
+	// Scale of the cars (1:87), used to compute scale speeds
+	ModelScale = 87
+)
+
+var (
+	// GPIO numbers of the on-pcb IO pins 0..7.
+	// Pins on GPIOs that share a PWM slice share its period.
+	IOPinGPIOs = [IOPinCount]uint8{29, 28, 27, 26, 15, 14, 13, 12}
+)
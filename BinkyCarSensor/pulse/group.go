@@ -0,0 +1,94 @@
+// Package pulse implements pulse outputs and maximum on-time safety limits
+// for a group of 8 output bits (the on-pcb output pins or a PCF8574 device).
+//
+// A bit in pulse mode is energized for its pulse duration each time the
+// requested bit changes from 0 to 1 and is then released automatically.
+// Any bit that is on for longer than its maximum on-time is switched off
+// and reported as tripped. To energize a released or tripped bit again,
+// the requested bit must be cleared and set again.
+package pulse
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+)
+
+// Group tracks the pulse & safety state of 8 output bits.
+type Group struct {
+	params    [8]config.PulseParams
+	requested uint8     // Last value requested by the host
+	value     uint8     // Value driven to the outputs
+	onSince   [8]uint32 // Time (ms) each driven bit was switched on
+	tripped   uint8     // Bits switched off by their maximum on-time
+}
+
+// Configure the pulse & safety settings of the bit with given index (0..7).
+func (g *Group) Configure(bit uint8, params config.PulseParams) {
+	g.params[bit] = params
+}
+
+// Set the value requested by the host at the given time (ms).
+// Returns the value to drive to the outputs.
+func (g *Group) Set(value uint8, now uint32) uint8 {
+	rising := value &^ g.requested
+	falling := g.requested &^ value
+	g.requested = value
+	for bit := uint8(0); bit < 8; bit++ {
+		mask := uint8(1) << bit
+		if rising&mask != 0 && g.value&mask == 0 {
+			// Energize
+			g.value |= mask
+			g.onSince[bit] = now
+		} else if falling&mask != 0 {
+			// Release
+			g.value &^= mask
+		}
+	}
+	g.Update(now)
+	return g.value
+}
+
+// Update releases pulses that ended and bits that exceeded their maximum
+// on-time at the given time (ms).
+// Returns the value to drive to the outputs and true if it changed.
+func (g *Group) Update(now uint32) (uint8, bool) {
+	changed := false
+	for bit := uint8(0); bit < 8; bit++ {
+		mask := uint8(1) << bit
+		if g.value&mask == 0 {
+			continue
+		}
+		p := g.params[bit]
+		elapsed := now - g.onSince[bit]
+		if p.Duration > 0 && elapsed >= uint32(p.Duration) {
+			// Pulse ended
+			g.value &^= mask
+			changed = true
+		} else if p.MaxOnTime > 0 && elapsed >= uint32(p.MaxOnTime) {
+			// Safety limit reached
+			g.value &^= mask
+			g.tripped |= mask
+			changed = true
+		}
+	}
+	return g.value, changed
+}
+
+// Value returns the value to drive to the outputs.
+func (g *Group) Value() uint8 {
+	return g.value
+}
+
+// Requested returns the last value requested by the host.
+func (g *Group) Requested() uint8 {
+	return g.requested
+}
+
+// Tripped returns the bits that were switched off by their maximum on-time.
+func (g *Group) Tripped() uint8 {
+	return g.tripped
+}
+
+// AckTripped clears the tripped state of the bits in the given mask.
+func (g *Group) AckTripped(mask uint8) {
+	g.tripped &^= mask
+}
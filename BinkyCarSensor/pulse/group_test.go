@@ -0,0 +1,93 @@
+package pulse
+
+import (
+	"testing"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+)
+
+// Kind of operation of a single step
+type op uint8
+
+const (
+	opSet op = iota
+	opUpdate
+	opAck
+)
+
+// A single step sets the requested value, updates the group or acknowledges tripped bits
+type step struct {
+	name    string
+	op      op
+	now     uint32
+	value   uint8 // Value to set or bits to acknowledge
+	output  uint8 // Expected output value
+	changed bool  // Expected change (update only)
+	tripped uint8 // Expected tripped bits
+}
+
+// Run all steps against the given group
+func runSteps(t *testing.T, g *Group, steps []step) {
+	t.Helper()
+	for _, s := range steps {
+		output, changed := g.Value(), false
+		switch s.op {
+		case opSet:
+			output = g.Set(s.value, s.now)
+		case opUpdate:
+			output, changed = g.Update(s.now)
+		case opAck:
+			g.AckTripped(s.value)
+		}
+		if output != s.output || g.Value() != s.output || changed != s.changed {
+			t.Errorf("%s: expected output 0x%02x (changed=%v), got 0x%02x (changed=%v)", s.name, s.output, s.changed, output, changed)
+		}
+		if g.Tripped() != s.tripped {
+			t.Errorf("%s: expected tripped 0x%02x, got 0x%02x", s.name, s.tripped, g.Tripped())
+		}
+	}
+}
+
+func TestPulseAndMaxOnTime(t *testing.T) {
+	g := &Group{}
+	g.Configure(0, config.PulseParams{Duration: 100})
+	g.Configure(1, config.PulseParams{MaxOnTime: 500})
+	// Bit 2 is a normal output
+	runSteps(t, g, []step{
+		{name: "energize", op: opSet, now: 1000, value: 0x07, output: 0x07},
+		{name: "pulse running", op: opUpdate, now: 1099, output: 0x07},
+		{name: "pulse ended", op: opUpdate, now: 1100, output: 0x06, changed: true},
+		{name: "set again without clearing", op: opSet, now: 1200, value: 0x07, output: 0x06},
+		{name: "just before max on-time", op: opUpdate, now: 1499, output: 0x06},
+		{name: "max on-time reached", op: opUpdate, now: 1500, output: 0x04, changed: true, tripped: 0x02},
+		{name: "tripped bit stays off", op: opSet, now: 1600, value: 0x07, output: 0x04, tripped: 0x02},
+		{name: "clear requested bits", op: opSet, now: 1700, value: 0x04, output: 0x04, tripped: 0x02},
+		{name: "energize again", op: opSet, now: 1800, value: 0x07, output: 0x07, tripped: 0x02},
+		{name: "acknowledge trip", op: opAck, value: 0x02, output: 0x07},
+		{name: "second pulse ended", op: opUpdate, now: 1900, output: 0x06, changed: true},
+		{name: "release before max on-time", op: opSet, now: 2000, value: 0x05, output: 0x04},
+		{name: "normal output stays on", op: opUpdate, now: 60000, output: 0x04},
+	})
+	if g.Requested() != 0x05 {
+		t.Errorf("expected requested value 0x05, got 0x%02x", g.Requested())
+	}
+}
+
+func TestMaxOnTimeCutsLongPulse(t *testing.T) {
+	g := &Group{}
+	g.Configure(3, config.PulseParams{Duration: 1000, MaxOnTime: 300})
+	runSteps(t, g, []step{
+		{name: "energize", op: opSet, now: 0, value: 0x08, output: 0x08},
+		{name: "cut by max on-time", op: opUpdate, now: 300, output: 0, changed: true, tripped: 0x08},
+	})
+}
+
+func TestPulseAcrossTimerWrap(t *testing.T) {
+	g := &Group{}
+	g.Configure(0, config.PulseParams{Duration: 100})
+	runSteps(t, g, []step{
+		{name: "energize before wrap", op: opSet, now: 0xffffffce, value: 0x01, output: 0x01},
+		{name: "running after wrap", op: opUpdate, now: 0x00000031, output: 0x01},
+		{name: "ended after wrap", op: opUpdate, now: 0x00000032, output: 0, changed: true},
+	})
+}
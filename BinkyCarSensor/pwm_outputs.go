@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"machine"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/pwmslices"
+)
+
+// pwmOutputs drives the IO pins that are in PWM mode.
+// Several IO pins share a PWM slice, so the slices are only configured
+// when needed and duty cycles are changed without disabling the slice.
+type pwmOutputs struct {
+	slices    *pwmslices.Allocator
+	conflicts uint8 // Bit N is set when the last PWM request of IO pin N was rejected
+	active    uint8 // Bit N is set when IO pin N is configured as PWM output
+	channels  [protocol.IOPinCount]uint8
+}
+
+// Initialize the PWM outputs
+func newPWMOutputs() *pwmOutputs {
+	return &pwmOutputs{
+		slices: pwmslices.New(protocol.IOPinGPIOs),
+	}
+}
+
+// Set the PWM duty cycle (0xffff = fully on) & period (in nanoseconds)
+// of the IO pin with given index.
+// The pin is switched to PWM mode if needed.
+func (p *pwmOutputs) set(ioIndex uint8, duty uint16, period uint64) error {
+	ch, configure, err := p.slices.Request(ioIndex, period)
+	if err != nil {
+		p.conflicts |= 1 << ioIndex
+		return err
+	}
+	p.conflicts &^= 1 << ioIndex
+	pwm := PWMBySlice[ch.Slice]
+	if configure {
+		println("Configure PWM slice ", ch.Slice)
+		if err := pwm.Configure(machine.PWMConfig{Period: period}); err != nil {
+			p.slices.Release(ioIndex)
+			p.active &^= 1 << ioIndex
+			return fmt.Errorf("Failed to configure PWM slice: %w", err)
+		}
+	}
+	if p.active&(1<<ioIndex) == 0 {
+		// Switch the pin to PWM mode once
+		channel, err := pwm.Channel(IO[ioIndex])
+		if err != nil {
+			p.slices.Release(ioIndex)
+			return fmt.Errorf("Failed to configure PWM channel: %w", err)
+		}
+		p.channels[ioIndex] = channel
+		p.active |= 1 << ioIndex
+	}
+	channel := p.channels[ioIndex]
+	targetValue := uint32(uint64(pwm.Top()) * uint64(duty) / 0xffff)
+	pwm.Set(channel, targetValue)
+	return nil
+}
+
+// Stop using PWM for the IO pin with given index.
+// The caller must reconfigure the pin afterwards.
+func (p *pwmOutputs) release(ioIndex uint8) {
+	ch, unused := p.slices.Release(ioIndex)
+	p.active &^= 1 << ioIndex
+	pwm := PWMBySlice[ch.Slice]
+	pwm.Set(ch.Channel, 0)
+	if unused {
+		pwm.Enable(false)
+	}
+}
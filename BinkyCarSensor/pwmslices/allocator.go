@@ -0,0 +1,125 @@
+// Package pwmslices tracks which IO pins use which RP2040 PWM slices.
+//
+// The RP2040 has 8 PWM slices with 2 channels (A & B) each.
+// GPIO N is driven by channel N&1 of slice (N>>1)&7, so several GPIOs
+// share a slice (and its period) and some even share a channel (and its duty cycle).
+// The Allocator rejects PWM requests that would change the output of another pin.
+package pwmslices
+
+import (
+	"errors"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+const (
+	// Number of PWM slices of the RP2040
+	SliceCount = 8
+	// Default PWM period in nanoseconds (60Hz)
+	DefaultPeriod = uint64(1e9) / 60
+)
+
+var (
+	// ErrPeriodConflict is returned when a pin requests a period that differs
+	// from the period used by another pin on the same slice.
+	ErrPeriodConflict = errors.New("PWM period conflicts with other pin on the same slice")
+	// ErrChannelConflict is returned when a pin requests a PWM channel that is
+	// already used by another pin.
+	ErrChannelConflict = errors.New("PWM channel is used by another pin")
+)
+
+// PeriodOfFrequency returns the PWM period in nanoseconds for the given
+// frequency in Hz. A frequency of 0 results in the default period.
+func PeriodOfFrequency(hz uint16) uint64 {
+	if hz == 0 {
+		return DefaultPeriod
+	}
+	return uint64(1e9) / uint64(hz)
+}
+
+// DutyOfByte converts an 8-bit PWM value (255 = fully on) into a
+// 16-bit duty cycle (0xffff = fully on).
+func DutyOfByte(value uint8) uint16 {
+	return uint16(value)<<8 | uint16(value)
+}
+
+// Channel identifies a single PWM channel.
+type Channel struct {
+	// Index of the slice (0..7)
+	Slice uint8
+	// Channel within the slice (0=A, 1=B)
+	Channel uint8
+}
+
+// ChannelOfGPIO returns the PWM channel that drives the GPIO with given number.
+func ChannelOfGPIO(gpio uint8) Channel {
+	return Channel{
+		Slice:   (gpio >> 1) % SliceCount,
+		Channel: gpio & 1,
+	}
+}
+
+// Allocator tracks the ownership of PWM slices & channels by IO pins.
+type Allocator struct {
+	channels [protocol.IOPinCount]Channel
+	owners   [SliceCount]uint8 // Bit N is set when IO pin N uses the slice
+	periods  [SliceCount]uint64
+}
+
+// New initializes an allocator for IO pins connected to the given GPIO numbers.
+func New(gpios [protocol.IOPinCount]uint8) *Allocator {
+	a := &Allocator{}
+	for pin, gpio := range gpios {
+		a.channels[pin] = ChannelOfGPIO(gpio)
+	}
+	return a
+}
+
+// Channel returns the PWM channel of the IO pin with given index.
+func (a *Allocator) Channel(pin uint8) Channel {
+	return a.channels[pin]
+}
+
+// Request claims the PWM channel of the IO pin with given index,
+// using the given period (in nanoseconds).
+// Returns the channel and true if the period of its slice must be (re)configured.
+// If the request conflicts with other pins, nothing changes.
+func (a *Allocator) Request(pin uint8, period uint64) (Channel, bool, error) {
+	ch := a.channels[pin]
+	others := a.owners[ch.Slice] &^ (1 << pin)
+	for other := uint8(0); other < protocol.IOPinCount; other++ {
+		if others&(1<<other) != 0 && a.channels[other] == ch {
+			return ch, false, ErrChannelConflict
+		}
+	}
+	if others != 0 && a.periods[ch.Slice] != period {
+		return ch, false, ErrPeriodConflict
+	}
+	configure := a.owners[ch.Slice] == 0 || a.periods[ch.Slice] != period
+	a.owners[ch.Slice] |= 1 << pin
+	a.periods[ch.Slice] = period
+	return ch, configure, nil
+}
+
+// Release the PWM channel of the IO pin with given index.
+// Returns the channel and true if no other pin uses its slice anymore.
+func (a *Allocator) Release(pin uint8) (Channel, bool) {
+	ch := a.channels[pin]
+	a.owners[ch.Slice] &^= 1 << pin
+	if a.owners[ch.Slice] == 0 {
+		a.periods[ch.Slice] = 0
+		return ch, true
+	}
+	return ch, false
+}
+
+// Owners returns a mask of the IO pins that use the slice with given index.
+func (a *Allocator) Owners(slice uint8) uint8 {
+	return a.owners[slice]
+}
+
+// Period returns the period (in nanoseconds) of the slice with given index
+// or 0 if the slice is not used.
+func (a *Allocator) Period(slice uint8) uint64 {
+	return a.periods[slice]
+}
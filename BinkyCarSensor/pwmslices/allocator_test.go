@@ -0,0 +1,98 @@
+package pwmslices
+
+import (
+	"testing"
+)
+
+// GPIO numbers of the IO pins of the board
+var testGPIOs = [8]uint8{29, 28, 27, 26, 15, 14, 13, 12}
+
+func TestChannelOfGPIO(t *testing.T) {
+	tests := []struct {
+		gpio     uint8
+		expected Channel
+	}{
+		{0, Channel{Slice: 0, Channel: 0}},
+		{13, Channel{Slice: 6, Channel: 1}},
+		{26, Channel{Slice: 5, Channel: 0}},
+		{29, Channel{Slice: 6, Channel: 1}},
+	}
+	for _, test := range tests {
+		if ch := ChannelOfGPIO(test.gpio); ch != test.expected {
+			t.Errorf("GPIO %d: expected %+v, got %+v", test.gpio, test.expected, ch)
+		}
+	}
+}
+
+func TestAllocator(t *testing.T) {
+	a := New(testGPIOs)
+	period50 := PeriodOfFrequency(50)
+	period1k := PeriodOfFrequency(1000)
+
+	// A single step requests (or releases) the PWM channel of a pin
+	type step struct {
+		name      string
+		pin       uint8
+		release   bool
+		period    uint64
+		configure bool  // Expected configure (request) or slice free (release)
+		err       error // Expected request error
+	}
+	steps := []step{
+		// Pins 2 & 3 share slice 5 with different channels
+		{"first pin on slice", 2, false, period50, true, nil},
+		{"same period on shared slice", 3, false, period50, false, nil},
+		{"re-request with same period", 2, false, period50, false, nil},
+		{"other period on shared slice", 3, false, period1k, false, ErrPeriodConflict},
+		{"release shared slice", 2, true, 0, false, nil},
+		{"change period of only owner", 3, false, period1k, true, nil},
+		{"other period after change", 2, false, period50, false, ErrPeriodConflict},
+		{"release last owner", 3, true, 0, true, nil},
+		{"request freed slice", 2, false, period50, true, nil},
+		// Pins 0 & 6 share channel B of slice 6
+		{"first pin on channel", 0, false, period50, true, nil},
+		{"same channel", 6, false, period50, false, ErrChannelConflict},
+		{"release channel", 0, true, 0, true, nil},
+		{"request freed channel", 6, false, period1k, true, nil},
+		// Pin 1 uses channel A of slice 6
+		{"other channel with other period", 1, false, period50, false, ErrPeriodConflict},
+		{"other channel with same period", 1, false, period1k, false, nil},
+	}
+	for _, s := range steps {
+		ch := a.Channel(s.pin)
+		if s.release {
+			if _, free := a.Release(s.pin); free != s.configure {
+				t.Errorf("%s: expected free=%v, got %v", s.name, s.configure, free)
+			}
+			continue
+		}
+		owners, period := a.Owners(ch.Slice), a.Period(ch.Slice)
+		reqCh, configure, err := a.Request(s.pin, s.period)
+		if err != s.err {
+			t.Errorf("%s: expected error %v, got %v", s.name, s.err, err)
+		}
+		if reqCh != ch || configure != s.configure {
+			t.Errorf("%s: expected %+v (configure=%v), got %+v (configure=%v)", s.name, ch, s.configure, reqCh, configure)
+		}
+		if err != nil {
+			// Rejected requests change nothing
+			if a.Owners(ch.Slice) != owners || a.Period(ch.Slice) != period {
+				t.Errorf("%s: rejected request changed slice %d", s.name, ch.Slice)
+			}
+		} else if a.Owners(ch.Slice)&(1<<s.pin) == 0 || a.Period(ch.Slice) != s.period {
+			t.Errorf("%s: expected pin to own slice %d with period %d, got owners 0x%02x with period %d",
+				s.name, ch.Slice, s.period, a.Owners(ch.Slice), a.Period(ch.Slice))
+		}
+	}
+
+	// Final state
+	if owners := a.Owners(5); owners != 0x04 {
+		t.Errorf("expected slice 5 to be owned by pin 2, got 0x%02x", owners)
+	}
+	if owners := a.Owners(6); owners != 0x42 {
+		t.Errorf("expected slice 6 to be owned by pins 1 & 6, got 0x%02x", owners)
+	}
+	if period := a.Period(7); period != 0 {
+		t.Errorf("expected unused slice 7 to have no period, got %d", period)
+	}
+}
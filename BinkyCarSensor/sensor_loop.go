@@ -5,57 +5,224 @@ import (
 	"image/color"
 	"time"
 
-	"github.com/binkynet/BinkyHardware/BinkyCarSensor/devices/ads1115"
-	"tinygo.org/x/drivers/ws2812"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/carsensors"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/i2cbus"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
 )
 
-// Keep probing sensors
-func probeSensors(sensors []*Sensor, adsDevs []*ads1115.Device,
-	led ws2812.Device, baseColor color.RGBA, sensorStatus chan uint8) {
+const (
+	// Interval between attempts to detect missing ADS1115 devices
+	adsProbeRetryInterval = time.Second * 3
+	// Maximum number of attempts to detect missing ADS1115 devices after the initial one.
+	// Every attempt causes failed transactions on the bus, so stop after a minute.
+	maxADSProbeRetries = 20
+)
+
+// Status of all car sensors, sent from the sensor loop to the i2c registers.
+type carSensorStatus struct {
+	// Number of car sensors
+	Count uint8
+	// Bit N is set when sensor N is active
+	State uint16
+	// Bit N is set while sensor N is calibrating
+	Calibrating uint16
+	// Bit N is set when the current (or last) detection of sensor N has negative polarity
+	Polarity uint16
+}
+
+// Result of the calibration of a single sensor,
+// sent from the sensor loop to the i2c registers.
+type calibrationResult struct {
+	Sensor      uint8
+	Calibration config.SensorCalibration
+}
+
+// Keep probing sensors.
+// As long as not all ADS1115 devices are found, detection of the missing devices
+// is retried in the background, up to maxADSProbeRetries times.
+func probeSensors(bus i2cbus.Bus, led *statusLed, sensorStatus chan<- carSensorStatus,
+	detectionConfig config.Detection, detectionChanges <-chan config.Detection,
+	calibrationRequests <-chan uint16, calibrationResults chan<- calibrationResult, supervisor *loopSupervisor) {
+	var adsDevs carsensors.Devices
+	// Sensors by slot (address index * SensorsPerADSDevice + channel), nil for missing devices
+	var sensors [protocol.MaxSensorCount]*carsensors.Sensor
+	// Number of sensor slots up to the last sensor found
+	sensorCount := 0
+	var baseColor color.RGBA
+	// Time of the last attempt to detect ADS1115 devices
+	var lastADSProbe uint32
+	initialADSProbe := true
+	adsProbeRetries := 0
+	// Sensors to calibrate once they are found
+	var pendingCalibration uint16
+	if detectionConfig.CalibrateAtBoot {
+		pendingCalibration = 0xffff
+	}
 	for {
-		if err := probeSensorsOnce(sensors, led, baseColor, sensorStatus); err != nil {
-			// Wait a bit
-			time.Sleep(time.Millisecond * 200)
-			// Reset ADS devices
-			for idx, dev := range adsDevs {
-				if err := resetADS1115Device(dev); err != nil {
-					println("Failed to reset ADS1115 device: ", idx, err)
-				} else {
-					println("Succesfully reset ADS1115 device: ", idx)
+		supervisor.alive(protocol.LoopSensor)
+
+		// Apply detection configuration changes
+		select {
+		case detectionConfig = <-detectionChanges:
+			for idx, s := range sensors {
+				if s == nil {
+					continue
 				}
+				s.Configure(detectionConfig.ForSensor(idx), detectionConfig.Detectors[idx], detectionConfig.Presence&(1<<idx) != 0)
+				s.SetCalibration(detectionConfig.Calibration[idx])
 			}
+		default:
+			// No changes
+		}
+
+		// Collect calibration requests
+		select {
+		case mask := <-calibrationRequests:
+			pendingCalibration |= mask
+		default:
+			// No requests
+		}
+
+		// Detect missing ADS1115 devices if needed
+		if !adsDevs.Complete() && (initialADSProbe || (adsProbeRetries < maxADSProbeRetries &&
+			millisSinceBoot()-lastADSProbe >= uint32(adsProbeRetryInterval/time.Millisecond))) {
+			if initialADSProbe {
+				led.show(colorBoot)
+			} else {
+				adsProbeRetries++
+			}
+			lastADSProbe = millisSinceBoot()
+			if adsDevs.Probe(bus) > 0 {
+				// Existing sensors keep their slot, so only add sensors of new devices
+				sensorCount = addSensors(&sensors, &adsDevs, detectionConfig)
+			}
+			baseColor = adsDevsColor(len(adsDevs.All()))
+			if initialADSProbe {
+				led.show(baseColor)
+				initialADSProbe = false
+			}
+		}
+		if sensorCount == 0 {
+			// Wait until trying again
+			supervisor.wait(protocol.LoopSensor)
+			led.sleep(adsProbeRetryInterval)
+			continue
+		}
+
+		// Start requested calibrations
+		if pendingCalibration != 0 {
+			for idx, s := range sensors {
+				if s != nil && pendingCalibration&(1<<idx) != 0 {
+					s.StartCalibration()
+				}
+			}
+			pendingCalibration = 0
+		}
+
+		if err := probeSensorsOnce(sensors[:sensorCount], led, baseColor, sensorStatus, calibrationResults, supervisor); err != nil {
+			// Wait a bit
+			supervisor.wait(protocol.LoopSensor)
+			led.sleep(time.Millisecond * 200)
+			supervisor.alive(protocol.LoopSensor)
+			// Reset ADS devices
+			carsensors.ResetDevices(adsDevs.All())
 		} else {
-			time.Sleep(probeInterval)
+			supervisor.wait(protocol.LoopSensor)
+			led.sleep(detectionConfig.ProbeInterval)
 		}
 	}
 }
 
-// Probe all sensors once
-func probeSensorsOnce(sensors []*Sensor,
-	led ws2812.Device, baseColor color.RGBA, sensorStatus chan uint8) error {
+// Create sensors for all channels of the given ADS1115 devices that have no sensors yet.
+// Each sensor gets a fixed slot based on the address of its device.
+// Returns the number of sensor slots up to the last sensor.
+func addSensors(sensors *[protocol.MaxSensorCount]*carsensors.Sensor, adsDevs *carsensors.Devices,
+	detectionConfig config.Detection) int {
+	count := 0
+	for devIdx, adsDev := range adsDevs {
+		if adsDev == nil {
+			continue
+		}
+		for channel := uint8(0); channel < protocol.SensorsPerADSDevice; channel++ {
+			idx := devIdx*protocol.SensorsPerADSDevice + int(channel)
+			if sensors[idx] == nil {
+				s := carsensors.New(adsDev, channel, detectionConfig.ForSensor(idx), detectionConfig.Detectors[idx],
+					detectionConfig.Presence&(1<<idx) != 0)
+				s.SetCalibration(detectionConfig.Calibration[idx])
+				sensors[idx] = s
+			}
+		}
+		count = (devIdx + 1) * protocol.SensorsPerADSDevice
+	}
+	return count
+}
+
+// Probe all sensors once, skipping empty slots.
+// A single probe can take up to half a second, so progress is reported
+// to the supervisor after every sensor.
+func probeSensorsOnce(sensors []*carsensors.Sensor,
+	led *statusLed, baseColor color.RGBA, sensorStatus chan<- carSensorStatus,
+	calibrationResults chan<- calibrationResult, supervisor *loopSupervisor) error {
 	activeCount := uint8(0)
 	var allErrs error
-	status := uint8(0)
+	status := uint16(0)
+	calibrating := uint16(0)
+	polarity := uint16(0)
 	for idx, s := range sensors {
-		if err := s.Probe(); err != nil {
+		if s == nil {
+			continue
+		}
+		if err := s.Probe(millisSinceBoot()); err != nil {
 			println("probe failed: ", err)
 			allErrs = errors.Join(allErrs, err)
 		}
+		supervisor.alive(protocol.LoopSensor)
 		if s.IsActive() {
 			activeCount++
 			status |= 1 << idx
 		}
+		if s.IsNegative() {
+			polarity |= 1 << idx
+		}
+		if result, done := s.CalibrationResult(); done {
+			calibrationResults <- calibrationResult{Sensor: uint8(idx), Calibration: result}
+		} else if s.IsCalibrating() {
+			calibrating |= 1 << idx
+		}
+	}
+	sensorStatus <- carSensorStatus{
+		Count:       uint8(len(sensors)),
+		State:       status,
+		Calibrating: calibrating,
+		Polarity:    polarity,
 	}
-	sensorStatus <- status
 
 	if allErrs != nil {
 		baseColor = color.RGBA{R: 255, G: 0, B: 0}
 	} else if activeCount > 0 {
 		baseColor.R = 0
 		baseColor.G = 0
-		baseColor.B = 120 + activeCount*16
+		baseColor.B = 120 + min(activeCount, 8)*16
 	}
-	led.WriteColors([]color.RGBA{baseColor})
+	led.show(baseColor)
 
 	return allErrs
 }
+
+// Returns the neopixel color used when there are no active detections
+// with the given number of ADS1115 devices.
+func adsDevsColor(count int) color.RGBA {
+	switch count {
+	case 0:
+		return colorNoAdsDevsFound
+	case 1:
+		return colorNoDetections1AdsDevFound
+	case 2:
+		return colorNoDetections2AdsDevsFound
+	case 3:
+		return colorNoDetections3AdsDevsFound
+	default:
+		return colorNoDetections4AdsDevsFound
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+// Configure all car sensor pairs from the configuration
+func (r *i2cRegisters) configurePairs() {
+	for idx, params := range r.config.Pairs {
+		r.pairs.Configure(idx, params)
+	}
+}
@@ -0,0 +1,137 @@
+// Package servo implements the motion of a hobby servo that moves
+// towards its target position at a limited speed.
+//
+// The caller updates the servo periodically and sends pulses of the
+// returned width at Frequency.
+package servo
+
+import (
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+const (
+	// Frequency of servo pulses in Hz
+	Frequency = 50
+	// Period of servo pulses in microseconds
+	PeriodMicros = 1000000 / Frequency
+	// Highest servo position
+	MaxPosition = 255
+
+	// Positions are tracked in 1/1000 of a position
+	positionScale = 1000
+)
+
+// Servo holds the state of a single servo.
+type Servo struct {
+	params      config.ServoParams
+	target      uint8
+	position    uint32 // Current position in 1/positionScale
+	lastUpdate  uint32 // Time of last update (ms)
+	reachedAt   uint32 // Time the target was reached (ms)
+	moving      bool
+	signal      bool
+	initialized bool
+}
+
+// New initializes a servo with given parameters.
+func New(params config.ServoParams) *Servo {
+	return &Servo{params: params}
+}
+
+// Configure the parameters of the servo.
+func (s *Servo) Configure(params config.ServoParams) {
+	s.params = params
+}
+
+// SetTarget sets the target position of the servo at the given time (ms).
+// The first target after power-on is reached immediately, since the
+// actual position of the servo is unknown.
+func (s *Servo) SetTarget(target uint8, now uint32) {
+	s.target = target
+	s.lastUpdate = now
+	s.signal = true
+	if !s.initialized || s.params.Speed == 0 {
+		s.initialized = true
+		s.position = uint32(target) * positionScale
+		s.moving = false
+		s.reachedAt = now
+		return
+	}
+	s.moving = s.position != uint32(target)*positionScale
+	if !s.moving {
+		s.reachedAt = now
+	}
+}
+
+// Update moves the servo towards its target for the time (ms) elapsed
+// since the last update.
+// Returns the pulse width in microseconds and true if pulses must be sent.
+func (s *Servo) Update(now uint32) (uint16, bool) {
+	elapsed := now - s.lastUpdate
+	s.lastUpdate = now
+	if s.moving {
+		target := uint32(s.target) * positionScale
+		step := uint32(s.params.Speed) * elapsed // Positions/s * ms = 1/1000 positions
+		if s.position < target {
+			s.position = min(s.position+step, target)
+		} else if s.position > target+step {
+			s.position -= step
+		} else {
+			s.position = target
+		}
+		if s.position == target {
+			s.moving = false
+			s.reachedAt = now
+		}
+	}
+	if !s.moving && s.signal && s.params.CutOffDelay > 0 && now-s.reachedAt >= uint32(s.params.CutOffDelay) {
+		s.signal = false
+	}
+	return s.Pulse(), s.signal
+}
+
+// Pulse returns the pulse width in microseconds of the current position.
+func (s *Servo) Pulse() uint16 {
+	lo, hi := int32(s.params.MinPulse), int32(s.params.MaxPulse)
+	return uint16(lo + (hi-lo)*int32(s.position)/(MaxPosition*positionScale))
+}
+
+// Target returns the target position.
+func (s *Servo) Target() uint8 {
+	return s.target
+}
+
+// Position returns the current position (rounded).
+func (s *Servo) Position() uint8 {
+	return uint8((s.position + positionScale/2) / positionScale)
+}
+
+// IsMoving returns true while the servo moves towards its target.
+func (s *Servo) IsMoving() bool {
+	return s.moving
+}
+
+// HasSignal returns true while pulses must be sent to the servo.
+func (s *Servo) HasSignal() bool {
+	return s.signal
+}
+
+// StatusRecord returns the status of the servo as register record of
+// protocol.ServoStatusSize bytes.
+func (s *Servo) StatusRecord() []byte {
+	flags := uint8(0)
+	if s.moving {
+		flags |= protocol.ServoFlagMoving
+	}
+	if s.signal {
+		flags |= protocol.ServoFlagSignal
+	}
+	return []byte{s.target, s.Position(), flags}
+}
+
+// Duty returns the PWM duty cycle (0xffff = fully on) for the given pulse width
+// in microseconds at the servo frequency.
+func Duty(pulse uint16) uint16 {
+	return uint16(uint32(pulse) * 0xffff / PeriodMicros)
+}
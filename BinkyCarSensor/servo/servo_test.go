@@ -0,0 +1,94 @@
+package servo
+
+import (
+	"testing"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/config"
+)
+
+// A single step sets the target (if setTarget) or updates the servo at the given time
+type step struct {
+	name      string
+	now       uint32
+	setTarget bool
+	target    uint8
+	position  uint8  // Expected position
+	pulse     uint16 // Expected pulse width
+	moving    bool   // Expected moving state
+	signal    bool   // Expected signal state
+}
+
+// Run all steps against the given servo
+func runSteps(t *testing.T, s *Servo, steps []step) {
+	t.Helper()
+	for _, st := range steps {
+		var pulse uint16
+		var signal bool
+		if st.setTarget {
+			s.SetTarget(st.target, st.now)
+			pulse, signal = s.Pulse(), s.HasSignal()
+		} else {
+			pulse, signal = s.Update(st.now)
+		}
+		if s.Position() != st.position || pulse != st.pulse {
+			t.Errorf("%s: expected position %d (pulse %d), got %d (pulse %d)", st.name, st.position, st.pulse, s.Position(), pulse)
+		}
+		if s.IsMoving() != st.moving || signal != st.signal || s.HasSignal() != st.signal {
+			t.Errorf("%s: expected moving=%v signal=%v, got moving=%v signal=%v", st.name, st.moving, st.signal, s.IsMoving(), signal)
+		}
+	}
+}
+
+func TestSpeedLimitedMotion(t *testing.T) {
+	s := New(config.ServoParams{MinPulse: 1000, MaxPulse: 2000, Speed: 100, CutOffDelay: 500})
+	runSteps(t, s, []step{
+		{name: "first target is immediate", now: 1000, setTarget: true, target: 0, position: 0, pulse: 1000, signal: true},
+		{name: "idle at first target", now: 1100, position: 0, pulse: 1000, signal: true},
+		{name: "start moving up", now: 1200, setTarget: true, target: 100, position: 0, pulse: 1000, moving: true, signal: true},
+		{name: "halfway after 500ms", now: 1700, position: 50, pulse: 1196, moving: true, signal: true},
+		{name: "target reached", now: 2200, position: 100, pulse: 1392, signal: true},
+		{name: "just before cut-off", now: 2699, position: 100, pulse: 1392, signal: true},
+		{name: "cut-off", now: 2700, position: 100, pulse: 1392, signal: false},
+		{name: "start moving down", now: 3000, setTarget: true, target: 90, position: 100, pulse: 1392, moving: true, signal: true},
+		{name: "step down", now: 3050, position: 95, pulse: 1372, moving: true, signal: true},
+		{name: "no overshoot", now: 4000, position: 90, pulse: 1352, signal: true},
+		{name: "same target again", now: 4100, setTarget: true, target: 90, position: 90, pulse: 1352, signal: true},
+		{name: "cut-off after same target", now: 4600, position: 90, pulse: 1352, signal: false},
+	})
+}
+
+func TestImmediateMotion(t *testing.T) {
+	// Speed 0 moves immediately, CutOffDelay 0 keeps the signal
+	s := New(config.ServoParams{MinPulse: 1000, MaxPulse: 2000})
+	runSteps(t, s, []step{
+		{name: "first target", now: 1000, setTarget: true, target: 255, position: 255, pulse: 2000, signal: true},
+		{name: "second target", now: 2000, setTarget: true, target: 0, position: 0, pulse: 1000, signal: true},
+		{name: "keeps signal", now: 60000, position: 0, pulse: 1000, signal: true},
+	})
+}
+
+func TestMotionAcrossTimerWrap(t *testing.T) {
+	s := New(config.ServoParams{MinPulse: 1000, MaxPulse: 2000, Speed: 100})
+	runSteps(t, s, []step{
+		{name: "first target", now: 0xffffff00, setTarget: true, target: 0, position: 0, pulse: 1000, signal: true},
+		{name: "start moving", now: 0xffffff9c, setTarget: true, target: 20, position: 0, pulse: 1000, moving: true, signal: true},
+		{name: "after wrap", now: 0x00000064, position: 20, pulse: 1078, signal: true},
+	})
+}
+
+func TestDuty(t *testing.T) {
+	tests := []struct {
+		pulse    uint16
+		expected uint16
+	}{
+		{0, 0},
+		{1000, 3276},
+		{1500, 4915},
+		{PeriodMicros, 0xffff},
+	}
+	for _, test := range tests {
+		if duty := Duty(test.pulse); duty != test.expected {
+			t.Errorf("pulse %d: expected duty %d, got %d", test.pulse, test.expected, duty)
+		}
+	}
+}
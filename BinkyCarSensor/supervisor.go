@@ -0,0 +1,141 @@
+package main
+
+import (
+	"device/rp"
+	"machine"
+	"sync/atomic"
+	"time"
+
+	"github.com/binkynet/BinkyHardware/BinkyCarSensor/protocol"
+)
+
+const (
+	// Timeout of the hardware watchdog
+	watchdogTimeout = time.Second * 2
+	// Interval between checks of the supervised loops
+	supervisorInterval = time.Millisecond * 250
+	// Time without progress after which a busy loop is considered stalled
+	loopStallTimeout = time.Second * 5
+	// Marks the contents of the watchdog scratch register as valid
+	stalledLoopsMagic = uint32(0xB1A70000)
+	// Number of supervised loops (protocol.LoopXyz)
+	supervisedLoopCount = 4
+)
+
+// Cause of the last reset, read at boot
+type resetStatus struct {
+	Cause        uint8 // protocol.ResetCauseXyz
+	StalledLoops uint8 // protocol.LoopXyz mask
+}
+
+// Returns the reset status register record
+func (s resetStatus) Record() []byte {
+	return []byte{s.Cause, s.StalledLoops}
+}
+
+// loopSupervisor feeds the hardware watchdog as long as all
+// firmware loops make progress.
+type loopSupervisor struct {
+	// Cause of the reset before this boot
+	resetStatus resetStatus
+	// Time of last progress (milliseconds since boot) per loop
+	lastProgress [supervisedLoopCount]atomic.Uint32
+	// Set while a loop waits for input (so it cannot stall)
+	idle [supervisedLoopCount]atomic.Bool
+}
+
+// Create a supervisor, reading the cause of the last reset
+func newLoopSupervisor() *loopSupervisor {
+	s := &loopSupervisor{
+		resetStatus: readResetStatus(),
+	}
+	if s.resetStatus.Cause != protocol.ResetCausePowerOn {
+		println("Reset by watchdog, cause: ", s.resetStatus.Cause, " stalled loops: ", s.resetStatus.StalledLoops)
+	}
+	return s
+}
+
+// Read the cause of the last reset from the watchdog registers
+func readResetStatus() resetStatus {
+	var s resetStatus
+	reason := rp.WATCHDOG.REASON.Get()
+	if reason&rp.WATCHDOG_REASON_TIMER != 0 {
+		s.Cause = protocol.ResetCauseWatchdog
+	} else if reason&rp.WATCHDOG_REASON_FORCE != 0 {
+		s.Cause = protocol.ResetCauseForced
+	}
+	if scratch := rp.WATCHDOG.SCRATCH0.Get(); s.Cause != protocol.ResetCausePowerOn && scratch&0xffff0000 == stalledLoopsMagic {
+		s.StalledLoops = uint8(scratch)
+	}
+	rp.WATCHDOG.SCRATCH0.Set(0)
+	return s
+}
+
+// Record progress of the given loop (protocol.LoopXyz)
+func (s *loopSupervisor) alive(loop uint8) {
+	idx := loopIndex(loop)
+	s.lastProgress[idx].Store(millisSinceBoot())
+	s.idle[idx].Store(false)
+}
+
+// Record that the given loop (protocol.LoopXyz) waits for input
+func (s *loopSupervisor) wait(loop uint8) {
+	idx := loopIndex(loop)
+	s.lastProgress[idx].Store(millisSinceBoot())
+	s.idle[idx].Store(true)
+}
+
+// Returns the mask of loops that are busy without progress for too long
+func (s *loopSupervisor) stalledLoops() uint8 {
+	now := millisSinceBoot()
+	limit := uint32(loopStallTimeout / time.Millisecond)
+	stalled := uint8(0)
+	for idx := range s.lastProgress {
+		if !s.idle[idx].Load() && now-s.lastProgress[idx].Load() > limit {
+			stalled |= 1 << idx
+		}
+	}
+	return stalled
+}
+
+// Start the hardware watchdog & keep feeding it while all loops make progress.
+// When a loop stalls, it is recorded in a watchdog scratch register,
+// so it can be reported after the watchdog reset.
+func (s *loopSupervisor) run() {
+	for idx := range s.lastProgress {
+		s.lastProgress[idx].Store(millisSinceBoot())
+	}
+	if err := machine.Watchdog.Configure(machine.WatchdogConfig{
+		TimeoutMillis: uint32(watchdogTimeout / time.Millisecond),
+	}); err != nil {
+		println("Failed to configure watchdog: ", err.Error())
+		return
+	}
+	if err := machine.Watchdog.Start(); err != nil {
+		println("Failed to start watchdog: ", err.Error())
+		return
+	}
+	lastStalled := uint8(0)
+	for {
+		stalled := s.stalledLoops()
+		if stalled != lastStalled {
+			println("Stalled loops: ", stalled)
+			rp.WATCHDOG.SCRATCH0.Set(stalledLoopsMagic | uint32(stalled))
+			lastStalled = stalled
+		}
+		if stalled == 0 {
+			machine.Watchdog.Update()
+		}
+		time.Sleep(supervisorInterval)
+	}
+}
+
+// Returns the index of the given loop (protocol.LoopXyz)
+func loopIndex(loop uint8) int {
+	for idx := 0; idx < supervisedLoopCount; idx++ {
+		if loop == 1<<idx {
+			return idx
+		}
+	}
+	panic("invalid loop")
+}